@@ -1,41 +1,382 @@
 package client
 
 import (
+	"expvar"
+	"fmt"
 	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
 
 	"github.com/emccode/libstorage/api/context"
 	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+)
+
+const (
+	defaultRetryBackoff = 100 * time.Millisecond
+
+	// maxRetryBackoff is the maximum amount of time to wait between retries.
+	maxRetryBackoff = 10 * time.Second
 )
 
 func init() {
 	context.RegisterCustomKey(transactionHeaderKey, context.CustomHeaderKey)
 	context.RegisterCustomKey(instanceIDHeaderKey, context.CustomHeaderKey)
 	context.RegisterCustomKey(localDevicesHeaderKey, context.CustomHeaderKey)
+	context.RegisterCustomKey(ifNoneMatchHeaderKey, context.CustomHeaderKey)
+	context.RegisterCustomKey(costTagsHeaderKey, context.CustomHeaderKey)
 }
 
 // Client is the libStorage API client.
 type client struct {
 	http.Client
-	host         string
-	logRequests  bool
-	logResponses bool
-	serverName   string
+	config         gofig.Config
+	transport      *http.Transport
+	host           string
+	logRequests    bool
+	logResponses   bool
+	serverName     string
+	acceptLanguage string
+
+	// responseHook and observer are invoked for the responses of every
+	// request. They may be set while requests are in-flight.
+	responseHook types.ResponseHookFunc
+	observer     types.ObserverFunc
+	hooksRWL     sync.RWMutex
+
+	// defaultRoundTripper is the round tripper used to send requests when the
+	// caller has not set one.
+	defaultRoundTripper http.RoundTripper
+
+	// wrapTransport, if set, returns the round tripper that records requests
+	// sent with, or replays them instead of sending them with, the transport
+	// of a service.
+	wrapTransport func(*http.Transport) http.RoundTripper
+
+	// compressThreshold is the size, in bytes, above which request bodies
+	// are gzipped. A value of zero disables compression.
+	compressThreshold int
+
+	// compression indicates whether gzip-encoded responses are requested.
+	compression bool
+
+	// responseTransforms are invoked, in order, after a response is decoded.
+	responseTransforms    []types.ResponseTransformFunc
+	responseTransformsRWL sync.RWMutex
+
+	// serviceAliases maps logical service names to concrete service names.
+	serviceAliases map[string]string
+
+	// notFoundAsNil indicates whether inspect operations return a nil result
+	// and a nil error when the server responds with a 404.
+	notFoundAsNil bool
+
+	// maxRetries is the maximum number of times a failed, retryable request
+	// is sent again.
+	maxRetries int
+
+	// retryBackoff is the amount of time to wait before the first retry. The
+	// wait doubles with each subsequent retry.
+	retryBackoff time.Duration
+
+	// retryNonIdempotent indicates whether requests with non-idempotent
+	// methods, such as POST, are retried on transport errors and 5xx
+	// responses.
+	retryNonIdempotent bool
+
+	// decodeTimeout is the maximum amount of time to wait for data while
+	// decoding a response body. A value of zero disables the timeout.
+	decodeTimeout time.Duration
+
+	// timeout is the maximum amount of time each attempt of a request may
+	// take. A value of zero disables the timeout.
+	timeout time.Duration
+
+	// hedgeDelay is the amount of time to wait for a response to a GET
+	// request before sending a second, identical request. A value of zero
+	// disables hedging.
+	hedgeDelay time.Duration
+
+	// maxDataAge is the maximum age of the data in the response to a read
+	// request. A value of zero disables the check.
+	maxDataAge time.Duration
+
+	// followLeader indicates whether redirects of mutating requests to a
+	// cluster leader are followed and the leader's host remembered.
+	followLeader bool
+
+	// leaderHost is the host to which mutating requests are sent, or an
+	// empty string if no leader redirect has been received.
+	leaderHost    string
+	leaderHostRWL sync.RWMutex
+
+	// failoverWarmup is the number of connections established to a new
+	// leader before the redirected request is sent to it.
+	failoverWarmup int
+
+	// maxVolumeSize is the maximum size, in GiB, of a volume created by the
+	// client. A value of zero disables the limit.
+	maxVolumeSize int64
+
+	// maxVolumesInResult is the maximum number of volumes accepted in a
+	// listing of all services' volumes. A value of zero disables the limit.
+	maxVolumesInResult int
+
+	// retryCodes are the server error codes that mark a failed request as
+	// retryable regardless of the request's HTTP method.
+	retryCodes []string
+
+	// nameCaseFold indicates whether volume names sent to and received from
+	// the server are lowercased.
+	nameCaseFold bool
+
+	// volumeNameTransform transforms volume names sent to and received from
+	// the server.
+	volumeNameTransform types.VolumeNameTransform
+
+	// driverFieldsTypes are the struct types into which volume fields are
+	// decoded, keyed by the lower-cased driver name.
+	driverFieldsTypes    map[string]reflect.Type
+	driverFieldsTypesRWL sync.RWMutex
+
+	// serviceDrivers are the lower-cased names of the services' drivers,
+	// keyed by the service name.
+	serviceDrivers    map[string]string
+	serviceDriversRWL sync.RWMutex
+
+	// auditor receives a record of each mutating operation.
+	auditor types.Auditor
+
+	// rateLimitStatus is the rate-limit information from the most recent
+	// response that included rate-limit headers.
+	rateLimitStatus    *types.RateLimitStatus
+	rateLimitStatusRWL sync.RWMutex
+
+	// serviceTransport returns the transport used for requests to a service.
+	serviceTransport types.ServiceTransportFunc
+
+	// serviceClients are the HTTP clients used for requests to services,
+	// keyed by the service name.
+	serviceClients    map[string]*http.Client
+	serviceClientsRWL sync.RWMutex
+
+	// instanceLocks serialize the operations that modify the attachments of
+	// an instance, keyed by the service name and instance ID. A lock is
+	// removed once no operation holds or waits for it.
+	instanceLocks    map[instanceLockKey]*instanceLock
+	instanceLocksRWL sync.Mutex
+
+	// reconcileAttach indicates whether a volume attach is retried and a
+	// retried attach that fails because the volume is already attached to
+	// the instance is treated as a success.
+	reconcileAttach bool
+
+	// autoDetach indicates whether the volumes attached by the client are
+	// detached when the client is closed.
+	autoDetach bool
+
+	// attachments are the volumes attached by the client, keyed by the
+	// service name and volume ID.
+	attachments    map[string]*attachment
+	attachmentsRWL sync.Mutex
+
+	// health records the outcomes of recent requests.
+	health health
+
+	// traceConns indicates whether connection lifecycle events are logged.
+	traceConns bool
+
+	// vars is the expvar map to which the client's metrics are published. A
+	// nil value indicates metrics are disabled.
+	vars *expvar.Map
 }
 
-// New returns a new API client.
-func New(host string, transport *http.Transport) types.APIClient {
-	return &client{
+// New returns a new API client. The provided configuration may be nil.
+func New(
+	host string,
+	transport *http.Transport,
+	config gofig.Config) types.APIClient {
+
+	if config == nil {
+		config = gofig.New()
+	}
+
+	// avoid assigning a typed nil to the http.Client's RoundTripper
+	var roundTripper http.RoundTripper = transport
+	if transport == nil {
+		roundTripper = nil
+	}
+	var wrapTransport func(*http.Transport) http.RoundTripper
+	if path := config.GetString(types.ConfigClientHTTPReplayFile); path != "" {
+		replay := newReplayTransport(path)
+		roundTripper = replay
+		wrapTransport = func(*http.Transport) http.RoundTripper {
+			return replay
+		}
+	} else if path := config.GetString(
+		types.ConfigClientHTTPRecordFile); path != "" {
+		record := newRecordTransport(roundTripper, path)
+		roundTripper = record
+		wrapTransport = func(t *http.Transport) http.RoundTripper {
+			return record.withTransport(t)
+		}
+	}
+
+	retryBackoff, err := time.ParseDuration(
+		config.GetString(types.ConfigClientHTTPRetryBackoff))
+	if err != nil {
+		retryBackoff = defaultRetryBackoff
+	}
+
+	// an unset or invalid decode timeout is parsed as zero, disabling it
+	decodeTimeout, _ := time.ParseDuration(
+		config.GetString(types.ConfigClientHTTPDecodeTimeout))
+
+	// as are the request timeout, hedge delay, and maximum data age
+	timeout, _ := time.ParseDuration(
+		config.GetString(types.ConfigClientHTTPTimeout))
+	hedgeDelay, _ := time.ParseDuration(
+		config.GetString(types.ConfigClientHTTPHedgeDelay))
+	maxDataAge, _ := time.ParseDuration(
+		config.GetString(types.ConfigClientHTTPMaxDataAge))
+
+	maxVolumeSize := int64(config.GetInt(types.ConfigClientMaxVolumeSize))
+	maxVolumesInResult := config.GetInt(types.ConfigClientMaxVolumesInResult)
+	retryNonIdempotent := config.GetBool(
+		types.ConfigClientHTTPRetryNonIdempotent)
+	traceConns := config.GetBool(types.ConfigClientHTTPTraceConnections)
+
+	var auditor types.Auditor
+	if path := config.GetString(types.ConfigClientAuditFile); path != "" {
+		auditor = newFileAuditor(path)
+	}
+
+	var volumeNameTransform types.VolumeNameTransform
+	if prefix := config.GetString(
+		types.ConfigClientVolumeNamePrefix); prefix != "" {
+		volumeNameTransform = &prefixTransform{prefix}
+	}
+
+	c := &client{
 		Client: http.Client{
-			Transport: transport,
+			Transport: roundTripper,
 		},
-		host: host,
+		followLeader: config.GetBool(
+			types.ConfigClientHTTPFollowLeaderRedirects),
+		failoverWarmup: config.GetInt(types.ConfigClientHTTPFailoverWarmup),
+		config:         config,
+		transport:      transport,
+		host:           host,
+		acceptLanguage: config.GetString(types.ConfigClientHTTPAcceptLanguage),
+		compressThreshold: config.GetInt(
+			types.ConfigClientHTTPCompressThreshold),
+		serviceAliases: parseServiceAliases(config),
+		notFoundAsNil:  config.GetBool(types.ConfigClientNotFoundAsNil),
+		maxRetries:     config.GetInt(types.ConfigClientHTTPMaxRetries),
+		retryBackoff:   retryBackoff,
+		decodeTimeout:  decodeTimeout,
+		timeout:        timeout,
+		hedgeDelay:     hedgeDelay,
+		maxDataAge:     maxDataAge,
+		maxVolumeSize:  maxVolumeSize,
+		retryCodes:     config.GetStringSlice(types.ConfigClientHTTPRetryCodes),
+		auditor:        auditor,
+
+		defaultRoundTripper: roundTripper,
+		wrapTransport:       wrapTransport,
+		volumeNameTransform: volumeNameTransform,
+		traceConns:          traceConns,
+		maxVolumesInResult:  maxVolumesInResult,
+		compression:         config.GetBool(types.ConfigClientHTTPCompression),
+		nameCaseFold:        config.GetBool(types.ConfigClientNameCaseFold),
+		retryNonIdempotent:  retryNonIdempotent,
+		driverFieldsTypes:   map[string]reflect.Type{},
+		serviceDrivers:      map[string]string{},
+		autoDetach:          config.GetBool(types.ConfigClientAutoDetachOnClose),
+		reconcileAttach:     config.GetBool(types.ConfigClientReconcileAttach),
+		attachments:         map[string]*attachment{},
+	}
+
+	c.health.configure(config)
+	c.initVars(config, transport)
+	if c.followLeader {
+		c.Client.CheckRedirect = checkRedirect
+	}
+
+	return c
+}
+
+func parseServiceAliases(config gofig.Config) map[string]string {
+	aliases := map[string]string{}
+	obj := config.Get(types.ConfigClientServiceAliases)
+	aliasesObj, ok := obj.(map[string]interface{})
+	if !ok {
+		return aliases
+	}
+	for alias, v := range aliasesObj {
+		if service, ok := v.(string); ok && service != "" {
+			aliases[strings.ToLower(alias)] = service
+		}
+	}
+	return aliases
+}
+
+// serviceName returns the concrete name of the service to which the provided
+// name is aliased, or the provided name if it is not an alias.
+func (c *client) serviceName(name string) string {
+	if service, ok := c.serviceAliases[strings.ToLower(name)]; ok {
+		return service
 	}
+	return name
+}
+
+// defaultAZ returns the availability zone applied to new volumes for the
+// given service when a request does not specify one.
+func (c *client) defaultAZ(service string) string {
+	return c.config.GetString(
+		fmt.Sprintf("%s.%s.defaultAZ", types.ConfigClient, service))
+}
+
+// checkVolumeSize returns an error if the size, in GiB, exceeds the maximum
+// size of a volume created by the client.
+func (c *client) checkVolumeSize(size *int64) error {
+	if c.maxVolumeSize > 0 && size != nil && *size > c.maxVolumeSize {
+		return utils.NewVolumeTooLargeError(*size, c.maxVolumeSize)
+	}
+	return nil
+}
+
+// withService returns the concrete name of the service and a copy of the
+// context with that name as the context's service name.
+func (c *client) withService(
+	ctx types.Context, service string) (types.Context, string) {
+
+	service = c.serviceName(service)
+	return ctx.WithValue(context.ServiceKey, service), service
 }
 
 func (c *client) ServerName() string {
 	return c.serverName
 }
 
+func (c *client) ServiceName(name string) string {
+	return c.serviceName(name)
+}
+
+func (c *client) RateLimitStatus() *types.RateLimitStatus {
+	c.rateLimitStatusRWL.RLock()
+	defer c.rateLimitStatusRWL.RUnlock()
+	if c.rateLimitStatus == nil {
+		return nil
+	}
+	status := *c.rateLimitStatus
+	return &status
+}
+
 func (c *client) LogRequests(enabled bool) {
 	c.logRequests = enabled
 }
@@ -43,3 +384,48 @@ func (c *client) LogRequests(enabled bool) {
 func (c *client) LogResponses(enabled bool) {
 	c.logResponses = enabled
 }
+
+func (c *client) ResponseHook(hook types.ResponseHookFunc) {
+	c.hooksRWL.Lock()
+	defer c.hooksRWL.Unlock()
+	c.responseHook = hook
+}
+
+func (c *client) Observer(observer types.ObserverFunc) {
+	c.hooksRWL.Lock()
+	defer c.hooksRWL.Unlock()
+	c.observer = observer
+}
+
+func (c *client) ResponseTransforms(
+	transforms ...types.ResponseTransformFunc) {
+
+	c.responseTransformsRWL.Lock()
+	defer c.responseTransformsRWL.Unlock()
+	c.responseTransforms = transforms
+}
+
+func (c *client) RoundTripper(rt http.RoundTripper) {
+	c.serviceClientsRWL.Lock()
+	defer c.serviceClientsRWL.Unlock()
+	if rt == nil {
+		rt = c.defaultRoundTripper
+	}
+	c.Client.Transport = rt
+	c.serviceClients = map[string]*http.Client{}
+}
+
+func (c *client) ServiceTransport(f types.ServiceTransportFunc) {
+	c.serviceClientsRWL.Lock()
+	defer c.serviceClientsRWL.Unlock()
+	c.serviceTransport = f
+	c.serviceClients = map[string]*http.Client{}
+}
+
+func (c *client) VolumeNameTransform(transform types.VolumeNameTransform) {
+	c.volumeNameTransform = transform
+}
+
+func (c *client) Auditor(auditor types.Auditor) {
+	c.auditor = auditor
+}
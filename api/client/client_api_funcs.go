@@ -4,9 +4,16 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"net/http"
 	"strconv"
+	"strings"
 
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/libstorage/api/context"
 	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
 )
 
 func (c *client) Root(ctx types.Context) ([]string, error) {
@@ -18,6 +25,39 @@ func (c *client) Root(ctx types.Context) ([]string, error) {
 	return reply, nil
 }
 
+func (c *client) Verify(ctx types.Context) error {
+
+	reply := []string{}
+	res, err := c.httpGet(ctx, "/", &reply)
+	if err != nil {
+		if _, ok := err.(types.TransportError); ok {
+			return err
+		}
+		return utils.NewNotLibStorageServerError(c.host, err)
+	}
+
+	if res.Header.Get(types.ServerNameHeader) == "" {
+		return utils.NewNotLibStorageServerError(
+			c.host, goof.New("missing server name header"))
+	}
+
+	for _, resource := range []string{"/services", "/volumes"} {
+		found := false
+		for _, u := range reply {
+			if strings.HasSuffix(u, resource) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return utils.NewNotLibStorageServerError(c.host, goof.WithField(
+				"resource", resource, "missing root resource"))
+		}
+	}
+
+	return nil
+}
+
 const (
 	ctxInstanceForSvc = 1000 + iota
 )
@@ -63,35 +103,88 @@ func (c *client) Services(
 	if _, err := c.httpGet(ctx, url, &reply); err != nil {
 		return nil, err
 	}
+	for _, si := range reply {
+		c.setServiceDriver(si)
+	}
 	return reply, nil
 }
 
 func (c *client) ServiceInspect(
 	ctx types.Context, name string) (*types.ServiceInfo, error) {
 
+	ctx, name = c.withService(ctx, name)
 	reply := &types.ServiceInfo{}
 
-	url := fmt.Sprintf("/services/%s", name)
+	url := urlPath("/services/%s", name)
 	if ctx.Value(ctxInstanceForSvc) != nil {
-		url = fmt.Sprintf("/services/%s?instance", name)
+		url = urlPath("/services/%s?instance", name)
 	}
 
 	if _, err := c.httpGet(ctx, url, &reply); err != nil {
 		return nil, err
 	}
+	c.setServiceDriver(reply)
 	return reply, nil
 }
 
+func (c *client) ServiceCapacity(
+	ctx types.Context, name string) (*types.CapacityInfo, error) {
+
+	ctx, name = c.withService(ctx, name)
+	reply := types.CapacityInfo{}
+
+	url := urlPath("/services/%s/capacity", name)
+	if _, err := c.httpGet(ctx, url, &reply); err != nil {
+		return nil, notImplemented(err, name, "ServiceCapacity", "capacity")
+	}
+	return &reply, nil
+}
+
 func (c *client) Volumes(
 	ctx types.Context,
 	attachments bool) (types.ServiceVolumeMap, error) {
 
+	// the listing is decoded one volume at a time so that a listing larger
+	// than the client's maximum is rejected before it is held in memory
 	reply := types.ServiceVolumeMap{}
-	url := fmt.Sprintf("/volumes?attachments=%v", attachments)
-	if _, err := c.httpGet(ctx, url, &reply); err != nil {
+	url := urlPath("/volumes?attachments=%v", attachments)
+	if err := c.decodeVolumes(ctx, url,
+		func(service string) {
+			reply[service] = types.VolumeMap{}
+		},
+		func(service, volumeID string, v *types.Volume) bool {
+			reply[service][volumeID] = v
+			return true
+		}); err != nil {
 		return nil, err
 	}
-	return reply, nil
+	if err := c.processReply(url, &reply); err != nil {
+		return nil, err
+	}
+	return c.decodeServiceVolumeMap(ctx, reply), nil
+}
+
+func (c *client) VolumesChanged(
+	ctx types.Context,
+	sinceETag string) (bool, string, error) {
+
+	if sinceETag != "" {
+		ctx = ctx.WithValue(ifNoneMatchHeaderKey, sinceETag)
+	}
+	// the server only routes GET requests for the listing, so the check is a
+	// conditional GET whose body, if the listing changed, is drained unread
+	res, err := c.httpGet(ctx, "/volumes", nil)
+	if err != nil {
+		return false, "", err
+	}
+	etag := res.Header.Get("ETag")
+	if res.StatusCode == http.StatusNotModified {
+		if etag == "" {
+			etag = sinceETag
+		}
+		return false, etag, nil
+	}
+	return true, etag, nil
 }
 
 func (c *client) VolumesByService(
@@ -99,12 +192,17 @@ func (c *client) VolumesByService(
 	service string,
 	attachments bool) (types.VolumeMap, error) {
 
+	ctx, service = c.withService(ctx, service)
 	reply := types.VolumeMap{}
-	url := fmt.Sprintf("/volumes/%s?attachments=%v", service, attachments)
+	url := urlPath("/volumes/%s?attachments=%v", service, attachments)
 	if _, err := c.httpGet(ctx, url, &reply); err != nil {
+		// the service validator responds with a 404 for an unknown service
+		if httpStatus(err) == http.StatusNotFound {
+			return nil, utils.NewServiceNotFoundError(service, err)
+		}
 		return nil, err
 	}
-	return reply, nil
+	return c.decodeVolumeMap(ctx, service, reply), nil
 }
 
 func (c *client) VolumeInspect(
@@ -112,13 +210,17 @@ func (c *client) VolumeInspect(
 	service, volumeID string,
 	attachments bool) (*types.Volume, error) {
 
+	ctx, service = c.withService(ctx, service)
 	reply := types.Volume{}
-	url := fmt.Sprintf(
+	url := urlPath(
 		"/volumes/%s/%s?attachments=%v", service, volumeID, attachments)
 	if _, err := c.httpGet(ctx, url, &reply); err != nil {
+		if c.isNotFoundAsNil(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
-	return &reply, nil
+	return c.decodeVolume(ctx, service, &reply), nil
 }
 
 func (c *client) VolumeCreate(
@@ -126,12 +228,35 @@ func (c *client) VolumeCreate(
 	service string,
 	request *types.VolumeCreateRequest) (*types.Volume, error) {
 
+	ctx, service = c.withService(ctx, service)
+
+	if request != nil {
+		size, err := request.NormalizedSize()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkVolumeSize(size); err != nil {
+			return nil, err
+		}
+		wireRequest := *request
+		wireRequest.Name = c.encodeVolumeName(request.Name)
+		wireRequest.Size = size
+		if request.AvailabilityZone == nil || *request.AvailabilityZone == "" {
+			if az := c.defaultAZ(service); az != "" {
+				wireRequest.AvailabilityZone = &az
+			}
+		}
+		request = &wireRequest
+	}
+
 	reply := types.Volume{}
-	if _, err := c.httpPost(ctx,
-		fmt.Sprintf("/volumes/%s", service), request, &reply); err != nil {
+	_, err := c.httpPost(ctx,
+		urlPath("/volumes/%s", service), request, &reply)
+	c.audit(ctx, "VolumeCreate", service, reply.ID, "", err)
+	if err != nil {
 		return nil, err
 	}
-	return &reply, nil
+	return c.decodeVolume(ctx, service, &reply), nil
 }
 
 func (c *client) VolumeCreateFromSnapshot(
@@ -139,13 +264,32 @@ func (c *client) VolumeCreateFromSnapshot(
 	service, snapshotID string,
 	request *types.VolumeCreateRequest) (*types.Volume, error) {
 
+	ctx, service = c.withService(ctx, service)
+
+	if request != nil {
+		size, err := request.NormalizedSize()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkVolumeSize(size); err != nil {
+			return nil, err
+		}
+		wireRequest := *request
+		wireRequest.Name = c.encodeVolumeName(request.Name)
+		wireRequest.Size = size
+		request = &wireRequest
+	}
+
 	reply := types.Volume{}
-	if _, err := c.httpPost(ctx,
-		fmt.Sprintf("/snapshots/%s/%s?create",
-			service, snapshotID), request, &reply); err != nil {
+	_, err := c.httpPost(ctx,
+		urlPath("/snapshots/%s/%s?create",
+			service, snapshotID), request, &reply)
+	c.audit(ctx,
+		"VolumeCreateFromSnapshot", service, reply.ID, snapshotID, err)
+	if err != nil {
 		return nil, err
 	}
-	return &reply, nil
+	return c.decodeVolume(ctx, service, &reply), nil
 }
 
 func (c *client) VolumeCopy(
@@ -153,24 +297,34 @@ func (c *client) VolumeCopy(
 	service, volumeID string,
 	request *types.VolumeCopyRequest) (*types.Volume, error) {
 
+	ctx, service = c.withService(ctx, service)
+
+	if request != nil {
+		wireRequest := *request
+		wireRequest.VolumeName = c.encodeVolumeName(request.VolumeName)
+		request = &wireRequest
+	}
+
 	reply := types.Volume{}
-	if _, err := c.httpPost(ctx,
-		fmt.Sprintf("/volumes/%s/%s?copy", service, volumeID),
-		request, &reply); err != nil {
+	_, err := c.httpPost(ctx,
+		urlPath("/volumes/%s/%s?copy", service, volumeID),
+		request, &reply)
+	c.audit(ctx, "VolumeCopy", service, volumeID, "", err)
+	if err != nil {
 		return nil, err
 	}
-	return &reply, nil
+	return c.decodeVolume(ctx, service, &reply), nil
 }
 
 func (c *client) VolumeRemove(
 	ctx types.Context,
 	service, volumeID string) error {
 
-	if _, err := c.httpDelete(ctx,
-		fmt.Sprintf("/volumes/%s/%s", service, volumeID), nil); err != nil {
-		return err
-	}
-	return nil
+	ctx, service = c.withService(ctx, service)
+	_, err := c.httpDelete(ctx,
+		urlPath("/volumes/%s/%s", service, volumeID), nil)
+	c.audit(ctx, "VolumeRemove", service, volumeID, "", err)
+	return err
 }
 
 func (c *client) VolumeAttach(
@@ -179,13 +333,105 @@ func (c *client) VolumeAttach(
 	volumeID string,
 	request *types.VolumeAttachRequest) (*types.Volume, string, error) {
 
+	ctx, service = c.withService(ctx, service)
+
+	// serialize attachments to the same instance to avoid device name races
+	defer c.lockInstance(ctx, service)()
+
+	if request == nil || !request.Force {
+		if vol := c.attachedVolume(ctx, service, volumeID); vol != nil {
+			return vol, "", nil
+		}
+	}
+
+	if request != nil && request.NextDeviceName != nil {
+		if err := checkDeviceNotInUse(
+			ctx, *request.NextDeviceName); err != nil {
+			return nil, "", err
+		}
+	}
+
+	attachCtx := ctx
+	if c.reconcileAttach {
+		attachCtx = ctx.WithValue(retryableKey, true)
+	}
+
 	reply := types.VolumeAttachResponse{}
-	if _, err := c.httpPost(ctx,
-		fmt.Sprintf("/volumes/%s/%s?attach",
-			service, volumeID), request, &reply); err != nil {
+	_, err := c.httpPost(attachCtx,
+		urlPath("/volumes/%s/%s?attach",
+			service, volumeID), request, &reply)
+	if err != nil && c.reconcileAttach {
+		vol := c.reconcileAttachError(ctx, service, volumeID, err)
+		if vol != nil {
+			c.audit(ctx, "VolumeAttach", service, volumeID, "", nil)
+			c.trackAttachment(ctx, service, volumeID)
+			return vol, "", nil
+		}
+	}
+	c.audit(ctx, "VolumeAttach", service, volumeID, "", err)
+	if err != nil {
 		return nil, "", err
 	}
-	return reply.Volume, reply.AttachToken, nil
+	c.trackAttachment(ctx, service, volumeID)
+	return c.decodeVolume(ctx, service, reply.Volume), reply.AttachToken, nil
+}
+
+// reconcileAttachError returns the volume if the error is the result of a
+// retried attach that failed because an earlier attempt attached the volume
+// to the instance in the context, otherwise nil is returned.
+func (c *client) reconcileAttachError(
+	ctx types.Context, service, volumeID string, err error) *types.Volume {
+
+	rerr, ok := err.(types.RetriedError)
+	if !ok || len(rerr.Attempts()) < 2 || !isAlreadyAttached(err) {
+		return nil
+	}
+	vol := c.attachedVolume(ctx, service, volumeID)
+	if vol != nil {
+		ctx.WithFields(log.Fields{
+			"volumeID": volumeID,
+			"attempts": len(rerr.Attempts()),
+		}).Info("reconciled retried attach with existing attachment")
+	}
+	return vol
+}
+
+// isAlreadyAttached returns a flag indicating whether the error is the
+// server's response to an attach of a volume that is already attached.
+func isAlreadyAttached(err error) bool {
+	switch httpStatus(err) {
+	case http.StatusConflict:
+		return true
+	case http.StatusInternalServerError:
+		return strings.Contains(
+			strings.ToLower(err.Error()), "already attached")
+	}
+	return false
+}
+
+// attachedVolume returns the volume if it is already attached to the instance
+// in the context, otherwise nil is returned.
+func (c *client) attachedVolume(
+	ctx types.Context, service, volumeID string) *types.Volume {
+
+	iid, ok := context.InstanceID(ctx)
+	if !ok {
+		return nil
+	}
+
+	vol, err := c.VolumeInspect(ctx, service, volumeID, true)
+	if err != nil || vol == nil {
+		return nil
+	}
+
+	for _, a := range vol.Attachments {
+		if a.InstanceID != nil && a.InstanceID.ID == iid.ID {
+			ctx.WithField("volumeID", volumeID).Debug(
+				"volume already attached")
+			return vol
+		}
+	}
+	return nil
 }
 
 func (c *client) VolumeDetach(
@@ -194,25 +440,36 @@ func (c *client) VolumeDetach(
 	volumeID string,
 	request *types.VolumeDetachRequest) (*types.Volume, error) {
 
+	ctx, service = c.withService(ctx, service)
+	defer c.lockInstance(ctx, service)()
+
 	reply := types.Volume{}
-	if _, err := c.httpPost(ctx,
-		fmt.Sprintf("/volumes/%s/%s?detach",
-			service, volumeID), request, &reply); err != nil {
+	_, err := c.httpPost(ctx,
+		urlPath("/volumes/%s/%s?detach",
+			service, volumeID), request, &reply)
+	c.audit(ctx, "VolumeDetach", service, volumeID, "", err)
+	if err != nil {
 		return nil, err
 	}
-	return &reply, nil
+	c.untrackAttachments(service, volumeID)
+	return c.decodeVolume(ctx, service, &reply), nil
 }
 
 func (c *client) VolumeDetachAll(
 	ctx types.Context,
 	request *types.VolumeDetachRequest) (types.ServiceVolumeMap, error) {
 
+	defer c.lockInstance(ctx, "")()
+
 	reply := types.ServiceVolumeMap{}
-	if _, err := c.httpPost(ctx,
-		fmt.Sprintf("/volumes?detach"), request, &reply); err != nil {
+	_, err := c.httpPost(ctx,
+		"/volumes?detach", request, &reply)
+	c.audit(ctx, "VolumeDetachAll", "", "", "", err)
+	if err != nil {
 		return nil, err
 	}
-	return reply, nil
+	c.untrackAttachments("", "")
+	return c.decodeServiceVolumeMap(ctx, reply), nil
 }
 
 func (c *client) VolumeDetachAllForService(
@@ -220,13 +477,18 @@ func (c *client) VolumeDetachAllForService(
 	service string,
 	request *types.VolumeDetachRequest) (types.VolumeMap, error) {
 
+	ctx, service = c.withService(ctx, service)
+	defer c.lockInstance(ctx, service)()
+
 	reply := types.VolumeMap{}
-	if _, err := c.httpPost(ctx,
-		fmt.Sprintf(
-			"/volumes/%s?detach", service), request, &reply); err != nil {
+	_, err := c.httpPost(ctx,
+		urlPath("/volumes/%s?detach", service), request, &reply)
+	c.audit(ctx, "VolumeDetachAllForService", service, "", "", err)
+	if err != nil {
 		return nil, err
 	}
-	return reply, nil
+	c.untrackAttachments(service, "")
+	return c.decodeVolumeMap(ctx, service, reply), nil
 }
 
 func (c *client) VolumeSnapshot(
@@ -235,15 +497,68 @@ func (c *client) VolumeSnapshot(
 	volumeID string,
 	request *types.VolumeSnapshotRequest) (*types.Snapshot, error) {
 
+	ctx, service = c.withService(ctx, service)
+
+	if request != nil {
+		opts, err := request.OptsWithHints()
+		if err != nil {
+			return nil, err
+		}
+		wireRequest := *request
+		wireRequest.Opts = opts
+		request = &wireRequest
+	}
+
 	reply := types.Snapshot{}
-	if _, err := c.httpPost(ctx,
-		fmt.Sprintf("/volumes/%s/%s?snapshot",
-			service, volumeID), request, &reply); err != nil {
+	_, err := c.httpPost(ctx,
+		urlPath("/volumes/%s/%s?snapshot",
+			service, volumeID), request, &reply)
+	c.audit(ctx, "VolumeSnapshot", service, volumeID, reply.ID, err)
+	if err != nil {
 		return nil, err
 	}
 	return &reply, nil
 }
 
+func (c *client) VolumeExport(
+	ctx types.Context,
+	service, volumeID string,
+	w io.Writer) error {
+
+	ctx, service = c.withService(ctx, service)
+	res, err := c.httpGet(ctx.WithValue(streamedKey, true),
+		urlPath("/volumes/%s/%s?export", service, volumeID), nil)
+	if err != nil {
+		return notImplemented(err, service, "VolumeExport", "export")
+	}
+	defer res.Body.Close()
+
+	if _, err := io.Copy(w, res.Body); err != nil {
+		if ctx.Err() != nil {
+			return newCanceledError(ctx)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *client) VolumeImport(
+	ctx types.Context,
+	service, volumeID string,
+	r io.Reader,
+	size int64) error {
+
+	ctx, service = c.withService(ctx, service)
+	_, err := c.httpPost(ctx,
+		urlPath("/volumes/%s/%s?import", service, volumeID),
+		&sizedReader{Reader: r, size: size}, nil)
+	c.audit(ctx, "VolumeImport", service, volumeID, "", err)
+	if err != nil {
+		return notImplemented(err, service, "VolumeImport", "import")
+	}
+	return nil
+}
+
 func (c *client) Snapshots(
 	ctx types.Context) (types.ServiceSnapshotMap, error) {
 
@@ -257,9 +572,10 @@ func (c *client) Snapshots(
 func (c *client) SnapshotsByService(
 	ctx types.Context, service string) (types.SnapshotMap, error) {
 
+	ctx, service = c.withService(ctx, service)
 	reply := types.SnapshotMap{}
 	if _, err := c.httpGet(ctx,
-		fmt.Sprintf("/snapshots/%s", service), &reply); err != nil {
+		urlPath("/snapshots/%s", service), &reply); err != nil {
 		return nil, err
 	}
 	return reply, nil
@@ -269,11 +585,15 @@ func (c *client) SnapshotInspect(
 	ctx types.Context,
 	service, snapshotID string) (*types.Snapshot, error) {
 
+	ctx, service = c.withService(ctx, service)
 	reply := types.Snapshot{}
 	if _, err := c.httpGet(ctx,
-		fmt.Sprintf(
+		urlPath(
 			"/snapshots/%s/%s", service, snapshotID), &reply); err != nil {
-		return nil, err
+		if c.isNotFoundAsNil(err) {
+			return nil, nil
+		}
+		return nil, notImplemented(err, service, "SnapshotInspect", "snapshot")
 	}
 	return &reply, nil
 }
@@ -282,11 +602,11 @@ func (c *client) SnapshotRemove(
 	ctx types.Context,
 	service, snapshotID string) error {
 
-	if _, err := c.httpDelete(ctx,
-		fmt.Sprintf("/snapshots/%s/%s", service, snapshotID), nil); err != nil {
-		return err
-	}
-	return nil
+	ctx, service = c.withService(ctx, service)
+	_, err := c.httpDelete(ctx,
+		urlPath("/snapshots/%s/%s", service, snapshotID), nil)
+	c.audit(ctx, "SnapshotRemove", service, "", snapshotID, err)
+	return err
 }
 
 func (c *client) SnapshotCopy(
@@ -294,10 +614,13 @@ func (c *client) SnapshotCopy(
 	service, snapshotID string,
 	request *types.SnapshotCopyRequest) (*types.Snapshot, error) {
 
+	ctx, service = c.withService(ctx, service)
 	reply := types.Snapshot{}
-	if _, err := c.httpPost(ctx,
-		fmt.Sprintf("/snapshots/%s/%s?copy",
-			service, snapshotID), request, &reply); err != nil {
+	_, err := c.httpPost(ctx,
+		urlPath("/snapshots/%s/%s?copy",
+			service, snapshotID), request, &reply)
+	c.audit(ctx, "SnapshotCopy", service, "", snapshotID, err)
+	if err != nil {
 		return nil, err
 	}
 	return &reply, nil
@@ -317,7 +640,7 @@ func (c *client) ExecutorHead(
 	ctx types.Context,
 	name string) (*types.ExecutorInfo, error) {
 
-	res, err := c.httpHead(ctx, fmt.Sprintf("/executors/%s", name))
+	res, err := c.httpHead(ctx, urlPath("/executors/%s", name))
 	if err != nil {
 		return nil, err
 	}
@@ -342,7 +665,8 @@ func (c *client) ExecutorHead(
 func (c *client) ExecutorGet(
 	ctx types.Context, name string) (io.ReadCloser, error) {
 
-	res, err := c.httpGet(ctx, fmt.Sprintf("/executors/%s", name), nil)
+	res, err := c.httpGet(
+		ctx.WithValue(streamedKey, true), urlPath("/executors/%s", name), nil)
 	if err != nil {
 		return nil, err
 	}
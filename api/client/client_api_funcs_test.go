@@ -0,0 +1,840 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/stretchr/testify/assert"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestServiceAliases(t *testing.T) {
+
+	var paths []string
+
+	config := gofig.New()
+	config.Set(types.ConfigClientServiceAliases, map[string]interface{}{
+		"default": "vfs-00",
+	})
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			writeJSON(w, 200, `{}`)
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := c.VolumesByService(ctx, "default", false)
+	assert.NoError(t, err)
+	_, err = c.VolumeInspect(ctx, "Default", "vol-000", false)
+	assert.NoError(t, err)
+	_, err = c.SnapshotsByService(ctx, "default")
+	assert.NoError(t, err)
+	_, err = c.VolumesByService(ctx, "vfs-01", false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"/volumes/vfs-00",
+		"/volumes/vfs-00/vol-000",
+		"/snapshots/vfs-00",
+		"/volumes/vfs-01",
+	}, paths)
+}
+
+func TestNotFoundAsNil(t *testing.T) {
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 404, "resource not found")
+	}
+	ctx := context.Background()
+
+	c, server := newTestClient(t, handler)
+	vol, err := c.VolumeInspect(ctx, "vfs", "vol-000", false)
+	assert.Error(t, err)
+	assert.Nil(t, vol)
+	assert.Equal(t, 404, httpStatus(err))
+	server.Close()
+
+	config := gofig.New()
+	config.Set(types.ConfigClientNotFoundAsNil, true)
+	c, server = newTestClientWithConfig(t, config, handler)
+	defer server.Close()
+
+	vol, err = c.VolumeInspect(ctx, "vfs", "vol-000", false)
+	assert.NoError(t, err)
+	assert.Nil(t, vol)
+
+	snap, err := c.SnapshotInspect(ctx, "vfs", "snap-000")
+	assert.NoError(t, err)
+	assert.Nil(t, snap)
+}
+
+func TestServiceCapacity(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/vfs/capacity":
+			writeJSON(w, 200, `{
+				"totalBytes": 9007199254740993,
+				"allocatedBytes": 9007199254740991,
+				"availableBytes": 2,
+				"volumeCount": 3
+			}`)
+		default:
+			writeError(w, 501, "not implemented")
+		}
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	ci, err := c.ServiceCapacity(ctx, "vfs")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), ci.TotalBytes)
+	assert.Equal(t, int64(9007199254740991), ci.AllocatedBytes)
+	assert.Equal(t, int64(2), ci.AvailableBytes)
+	assert.Equal(t, int64(3), ci.VolumeCount)
+
+	ci, err = c.ServiceCapacity(ctx, "scaleio")
+	assert.Nil(t, ci)
+	assertCapabilityNotImplemented(t, err, "ServiceCapacity", "capacity")
+}
+
+func TestVolumeCreateDefaultAZ(t *testing.T) {
+
+	var received types.VolumeCreateRequest
+
+	config := gofig.New()
+	config.Set(types.ConfigClient+".vfs.defaultAZ", "us-east-1a")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			received = types.VolumeCreateRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			writeJSON(w, 200, `{"id":"vfs-000"}`)
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	request := &types.VolumeCreateRequest{Name: "v0"}
+	_, err := c.VolumeCreate(ctx, "vfs", request)
+	assert.NoError(t, err)
+	if assert.NotNil(t, received.AvailabilityZone) {
+		assert.Equal(t, "us-east-1a", *received.AvailabilityZone)
+	}
+	assert.Nil(t, request.AvailabilityZone)
+
+	az := "us-east-1b"
+	_, err = c.VolumeCreate(ctx, "vfs",
+		&types.VolumeCreateRequest{Name: "v1", AvailabilityZone: &az})
+	assert.NoError(t, err)
+	if assert.NotNil(t, received.AvailabilityZone) {
+		assert.Equal(t, "us-east-1b", *received.AvailabilityZone)
+	}
+
+	_, err = c.VolumeCreate(ctx, "scaleio",
+		&types.VolumeCreateRequest{Name: "v2"})
+	assert.NoError(t, err)
+	assert.Nil(t, received.AvailabilityZone)
+}
+
+func TestVolumeAttachAlreadyAttached(t *testing.T) {
+
+	var attaches int
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			attaches++
+			writeJSON(w, 200, `{"volume":{"id":"vfs-000"},"attachToken":"t0"}`)
+			return
+		}
+		writeJSON(w, 200, `{"id":"vfs-000","attachments":[`+
+			`{"instanceID":{"id":"iid-000","driver":"vfs"},"volumeID":"vfs-000"}]}`)
+	})
+	defer server.Close()
+
+	ctx := context.Background().WithValue(
+		context.InstanceIDKey, &types.InstanceID{ID: "iid-000", Driver: "vfs"})
+
+	vol, token, err := c.VolumeAttach(
+		ctx, "vfs", "vfs-000", &types.VolumeAttachRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-000", vol.ID)
+	assert.Equal(t, "", token)
+	assert.Equal(t, 0, attaches)
+
+	vol, token, err = c.VolumeAttach(
+		ctx, "vfs", "vfs-000", &types.VolumeAttachRequest{Force: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-000", vol.ID)
+	assert.Equal(t, "t0", token)
+	assert.Equal(t, 1, attaches)
+
+	ctx = context.Background().WithValue(
+		context.InstanceIDKey, &types.InstanceID{ID: "iid-001", Driver: "vfs"})
+	_, token, err = c.VolumeAttach(
+		ctx, "vfs", "vfs-000", &types.VolumeAttachRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "t0", token)
+	assert.Equal(t, 2, attaches)
+}
+
+func TestVolumeAttachReconcile(t *testing.T) {
+
+	var (
+		attaches int
+		attached bool
+	)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			attaches++
+			if attached {
+				writeError(w, 500, "volume already attached to instance")
+				return
+			}
+			// the volume is attached but the response is lost
+			attached = true
+			writeError(w, 503, "service unavailable")
+			return
+		}
+		if !attached {
+			writeJSON(w, 200, `{"id":"vfs-000"}`)
+			return
+		}
+		writeJSON(w, 200, `{"id":"vfs-000","attachments":[`+
+			`{"instanceID":{"id":"iid-000","driver":"vfs"},"volumeID":"vfs-000"}]}`)
+	}
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxRetries, 2)
+	config.Set(types.ConfigClientHTTPRetryBackoff, "1ms")
+
+	ctx := context.Background().WithValue(
+		context.InstanceIDKey, &types.InstanceID{ID: "iid-000", Driver: "vfs"})
+
+	// without reconciliation an attach is not retried
+	c, server := newTestClientWithConfig(t, config, handler)
+	_, _, err := c.VolumeAttach(
+		ctx, "vfs", "vfs-000", &types.VolumeAttachRequest{})
+	assert.Equal(t, 503, httpStatus(err))
+	assert.Equal(t, 1, attaches)
+	server.Close()
+
+	config.Set(types.ConfigClientReconcileAttach, true)
+	attaches, attached = 0, false
+	c, server = newTestClientWithConfig(t, config, handler)
+	defer server.Close()
+
+	vol, token, err := c.VolumeAttach(
+		ctx, "vfs", "vfs-000", &types.VolumeAttachRequest{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, vol) {
+		assert.Equal(t, "vfs-000", vol.ID)
+		assert.Len(t, vol.Attachments, 1)
+	}
+	assert.Equal(t, "", token)
+	assert.Equal(t, 2, attaches)
+
+	// a volume attached to another instance is not reconciled
+	attaches = 0
+	ctx = context.Background().WithValue(
+		context.InstanceIDKey, &types.InstanceID{ID: "iid-001", Driver: "vfs"})
+	_, _, err = c.VolumeAttach(
+		ctx, "vfs", "vfs-000", &types.VolumeAttachRequest{Force: true})
+	assert.True(t, isAlreadyAttached(err), "%v", err)
+	assert.Equal(t, 1, attaches)
+}
+
+func newTestTar(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		data := []byte(strings.Repeat(name, 1024))
+		if err := tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type cancelWriter struct {
+	io.Writer
+	cancel func()
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	return w.Writer.Write(p)
+}
+
+func TestVolumeExport(t *testing.T) {
+
+	fixture := newTestTar(t)
+	release := make(chan struct{})
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/volumes/vfs/vfs-000":
+			w.Header().Set("Content-Type", "application/x-tar")
+			w.Write(fixture)
+		case "/volumes/vfs/vfs-001":
+			w.Header().Set("Content-Type", "application/x-tar")
+			w.Write(fixture[:512])
+			w.(http.Flusher).Flush()
+			<-release
+		default:
+			writeError(w, 501, "not implemented")
+		}
+	})
+	defer server.Close()
+	defer close(release)
+
+	buf := &bytes.Buffer{}
+	err := c.VolumeExport(context.Background(), "vfs", "vfs-000", buf)
+	assert.NoError(t, err)
+	assert.Equal(t, fixture, buf.Bytes())
+
+	err = c.VolumeExport(context.Background(), "scaleio", "sio-000", buf)
+	assertCapabilityNotImplemented(t, err, "VolumeExport", "export")
+
+	goCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+	err = c.VolumeExport(context.New(goCtx), "vfs", "vfs-001",
+		&cancelWriter{ioutil.Discard, cancel})
+	assert.True(t, errors.Is(err, gocontext.Canceled))
+}
+
+func TestVolumeImport(t *testing.T) {
+
+	fixture := newTestTar(t)
+
+	var (
+		received         []byte
+		contentLength    int64
+		transferEncoding []string
+	)
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/volumes/vfs/vfs-000" {
+			writeError(w, 501, "not implemented")
+			return
+		}
+		contentLength = r.ContentLength
+		transferEncoding = r.TransferEncoding
+		received, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(200)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	err := c.VolumeImport(ctx, "vfs", "vfs-000",
+		bytes.NewReader(fixture), int64(len(fixture)))
+	assert.NoError(t, err)
+	assert.Equal(t, fixture, received)
+	assert.Equal(t, int64(len(fixture)), contentLength)
+	assert.Nil(t, transferEncoding)
+
+	err = c.VolumeImport(ctx, "vfs", "vfs-000",
+		ioutil.NopCloser(bytes.NewReader(fixture)), -1)
+	assert.NoError(t, err)
+	assert.Equal(t, fixture, received)
+	assert.Equal(t, int64(-1), contentLength)
+	assert.Equal(t, []string{"chunked"}, transferEncoding)
+
+	err = c.VolumeImport(ctx, "scaleio", "sio-000", bytes.NewReader(nil), 0)
+	assertCapabilityNotImplemented(t, err, "VolumeImport", "import")
+}
+
+func TestVolumeImportCancel(t *testing.T) {
+
+	fixture := newTestTar(t)
+	receivedc := make(chan int, 1)
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		receivedc <- int(n)
+	})
+	defer server.Close()
+
+	goCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		pw.Write(fixture[:512])
+		cancel()
+	}()
+
+	err := c.VolumeImport(context.New(goCtx), "vfs", "vfs-000", pr, -1)
+	assert.True(t, errors.Is(err, gocontext.Canceled))
+	assert.True(t, <-receivedc < len(fixture))
+}
+
+func TestVolumeCreateSize(t *testing.T) {
+
+	var received map[string]interface{}
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+		writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := c.VolumeCreate(ctx, "vfs",
+		(&types.VolumeCreateRequest{Name: "v0"}).WithSizeGiB(8))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 8, received["size"])
+
+	_, err = c.VolumeCreate(ctx, "vfs",
+		(&types.VolumeCreateRequest{Name: "v0"}).WithSizeBytes(8*types.GiB))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 8, received["size"])
+
+	for _, request := range []*types.VolumeCreateRequest{
+		(&types.VolumeCreateRequest{Name: "v0"}).WithSizeGiB(0),
+		(&types.VolumeCreateRequest{Name: "v0"}).WithSizeGiB(-1),
+		(&types.VolumeCreateRequest{Name: "v0"}).WithSizeBytes(-types.GiB),
+		(&types.VolumeCreateRequest{Name: "v0"}).WithSizeBytes(types.GiB + 1),
+	} {
+		received = nil
+		_, err = c.VolumeCreate(ctx, "vfs", request)
+		assert.EqualError(t, err, "invalid volume size")
+		assert.Nil(t, received)
+	}
+}
+
+func TestMaxVolumeSize(t *testing.T) {
+
+	requests := 0
+
+	config := gofig.New()
+	config.Set(types.ConfigClientMaxVolumeSize, 100)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := c.VolumeCreate(ctx, "vfs",
+		(&types.VolumeCreateRequest{Name: "v0"}).WithSizeGiB(10000))
+	assert.IsType(t, &types.ErrVolumeTooLarge{}, err)
+	_, err = c.VolumeCreateFromSnapshot(ctx, "vfs", "snap-000",
+		(&types.VolumeCreateRequest{Name: "v0"}).WithSizeBytes(101*types.GiB))
+	assert.IsType(t, &types.ErrVolumeTooLarge{}, err)
+	assert.Equal(t, 0, requests)
+
+	_, err = c.VolumeCreate(ctx, "vfs",
+		(&types.VolumeCreateRequest{Name: "v0"}).WithSizeGiB(100))
+	assert.NoError(t, err)
+	_, err = c.VolumeCreate(ctx, "vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestMaxVolumesInResult(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientMaxVolumesInResult, 2)
+
+	body := `{"vfs":{"vfs-000":{"id":"vfs-000"},"vfs-001":{"id":"vfs-001"}}}`
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, 200, body)
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	svm, err := c.Volumes(ctx, false)
+	assert.NoError(t, err)
+	assert.Len(t, svm["vfs"], 2)
+
+	body = `{"vfs":{"vfs-000":{"id":"vfs-000"},"vfs-001":{"id":"vfs-001"}},` +
+		`"ebs":{"ebs-000":{"id":"ebs-000"}}}`
+	svm, err = c.Volumes(ctx, false)
+	assert.Nil(t, svm)
+	assert.IsType(t, &types.ErrResultTooLarge{}, err)
+	assert.Equal(t, 3, err.(goof.Goof).Fields()["count"])
+	assert.Equal(t, 2, err.(goof.Goof).Fields()["maxCount"])
+}
+
+func TestMaxVolumesInResultStopsDecoding(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientMaxVolumesInResult, 2)
+
+	// the listing is invalid after the volume that exceeds the maximum, so
+	// decoding it to the end would fail with a syntax error instead
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, 200, `{"vfs":{"vfs-000":{"id":"vfs-000"},`+
+				`"vfs-001":{"id":"vfs-001"},"vfs-002":{"id":"vfs-002"},`+
+				`"vfs-003":{"id":`+strings.Repeat("x", 1<<16))
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := c.Volumes(ctx, false)
+	assert.IsType(t, &types.ErrResultTooLarge{}, err)
+
+	vols, errs := c.VolumesStream(ctx, false)
+	n := 0
+	for range vols {
+		n++
+	}
+	assert.Equal(t, 2, n)
+	assert.IsType(t, &types.ErrResultTooLarge{}, <-errs)
+}
+
+func TestMaxVolumesInResultClosesBody(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientMaxVolumesInResult, 2)
+
+	// the server does not finish the listing until the test ends, so the
+	// client must not read the rest of the body after it is too large
+	done := make(chan struct{})
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			w.Write([]byte(`{"vfs":{"vfs-000":{"id":"vfs-000"},` +
+				`"vfs-001":{"id":"vfs-001"},"vfs-002":{"id":"vfs-002"},`))
+			w.(http.Flusher).Flush()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+			}
+		})
+	defer server.Close()
+	defer close(done)
+
+	start := time.Now()
+	_, err := c.Volumes(context.Background(), false)
+	assert.IsType(t, &types.ErrResultTooLarge{}, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestVolumesChanged(t *testing.T) {
+
+	var (
+		method      string
+		ifNoneMatch string
+		conns       int32
+	)
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		if ifNoneMatch == `"v1"` {
+			w.Header().Set("ETag", `"v1"`)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		writeJSON(w, 200, `{"vfs":{}}`)
+	})
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	defer server.Close()
+
+	ctx := context.Background()
+
+	changed, etag, err := c.VolumesChanged(ctx, `"v1"`)
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", method)
+	assert.Equal(t, `"v1"`, ifNoneMatch)
+	assert.False(t, changed)
+	assert.Equal(t, `"v1"`, etag)
+
+	changed, etag, err = c.VolumesChanged(ctx, `"v0"`)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, `"v2"`, etag)
+
+	changed, etag, err = c.VolumesChanged(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "", ifNoneMatch)
+	assert.True(t, changed)
+	assert.Equal(t, `"v2"`, etag)
+
+	// the body of a changed listing is drained so the connection is reused
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
+
+func TestVolumesByServiceNotFound(t *testing.T) {
+
+	var query string
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		switch r.URL.Path {
+		case "/volumes/vfs":
+			writeJSON(w, 200, `{"vfs-000":{"id":"vfs-000",`+
+				`"attachments":[{"volumeID":"vfs-000"}]}}`)
+		case "/volumes/empty":
+			writeJSON(w, 200, `{}`)
+		default:
+			writeError(w, 404, "resource not found")
+		}
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	vols, err := c.VolumesByService(ctx, "vfs", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "attachments=true", query)
+	if assert.Contains(t, vols, "vfs-000") {
+		assert.Len(t, vols["vfs-000"].Attachments, 1)
+	}
+
+	vols, err = c.VolumesByService(ctx, "empty", false)
+	assert.NoError(t, err)
+	assert.NotNil(t, vols)
+	assert.Empty(t, vols)
+
+	vols, err = c.VolumesByService(ctx, "missing", false)
+	assert.Nil(t, vols)
+	assert.IsType(t, &types.ErrServiceNotFound{}, err)
+	assert.Equal(t, "missing", err.(goof.Goof).Fields()["service"])
+}
+
+func TestVolumeSnapshotHints(t *testing.T) {
+
+	var received map[string]interface{}
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+		writeJSON(w, 200, `{"id":"snap-000","volumeID":"vfs-000"}`)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+	retention := 30 * 24 * time.Hour
+	schedule := "0 2 * * *"
+
+	request := &types.VolumeSnapshotRequest{
+		SnapshotName: "s0",
+		Opts:         map[string]interface{}{"tag": "nightly"},
+		Retention:    &retention,
+		Schedule:     &schedule,
+	}
+	_, err := c.VolumeSnapshot(ctx, "vfs", "vfs-000", request)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"snapshotName": "s0",
+		"opts": map[string]interface{}{
+			"tag":       "nightly",
+			"retention": "720h0m0s",
+			"schedule":  "0 2 * * *",
+		},
+	}, received)
+	assert.Len(t, request.Opts, 1)
+
+	_, err = c.VolumeSnapshot(ctx, "vfs", "vfs-000",
+		&types.VolumeSnapshotRequest{SnapshotName: "s0"})
+	assert.NoError(t, err)
+	assert.Equal(t,
+		map[string]interface{}{"snapshotName": "s0"}, received)
+
+	received = nil
+	retention = -time.Hour
+	_, err = c.VolumeSnapshot(ctx, "vfs", "vfs-000", request)
+	assert.EqualError(t, err, "invalid snapshot retention")
+	assert.Nil(t, received)
+}
+
+func TestVolumeSnapshotNotFound(t *testing.T) {
+
+	var path, query string
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		writeError(w, 404, "resource not found")
+	})
+	defer server.Close()
+
+	snap, err := c.VolumeSnapshot(context.Background(), "vfs", "vfs-999",
+		&types.VolumeSnapshotRequest{SnapshotName: "s0"})
+	assert.Nil(t, snap)
+	assert.Equal(t, "/volumes/vfs/vfs-999", path)
+	assert.Equal(t, "snapshot", query)
+	if assert.IsType(t, &types.HTTPError{}, err) {
+		assert.Equal(t, 404, err.(*types.HTTPError).StatusCode)
+	}
+}
+
+func TestVerify(t *testing.T) {
+
+	root := `["http://host/executors","http://host/services",` +
+		`"http://host/volumes"]`
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		valid   bool
+	}{
+		{"libstorage", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(types.ServerNameHeader, "server-0")
+			writeJSON(w, 200, root)
+		}, true},
+		{"html", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>It works!</body></html>"))
+		}, false},
+		{"missing header", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, 200, root)
+		}, false},
+		{"missing resources", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(types.ServerNameHeader, "server-0")
+			writeJSON(w, 200, `["http://host/api"]`)
+		}, false},
+		{"not found", func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}, false},
+	}
+
+	for _, test := range tests {
+		c, server := newTestClient(t, test.handler)
+		err := c.Verify(context.Background())
+		server.Close()
+		if test.valid {
+			assert.NoError(t, err, test.name)
+			continue
+		}
+		assert.IsType(t, &types.ErrNotLibStorageServer{}, err, test.name)
+	}
+
+	// a server that cannot be reached is not known to be the wrong server
+	c, server := newTestClient(t, nil)
+	server.Close()
+	err := c.Verify(context.Background())
+	assert.Error(t, err)
+	_, ok := err.(*types.ErrNotLibStorageServer)
+	assert.False(t, ok)
+}
+
+func TestEscapedPathSegments(t *testing.T) {
+
+	var paths []string
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		writeJSON(w, 200, `{"id":"a/b c","name":"v0"}`)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := c.VolumeInspect(ctx, "vfs", "a/b c", false)
+	assert.NoError(t, err)
+	_, err = c.VolumeCreate(ctx, "my svc", &types.VolumeCreateRequest{Name: "v0"})
+	assert.NoError(t, err)
+	assert.NoError(t, c.VolumeRemove(ctx, "vfs", "a/b c"))
+
+	assert.Equal(t, []string{
+		"/volumes/vfs/a%2Fb%20c",
+		"/volumes/my%20svc",
+		"/volumes/vfs/a%2Fb%20c",
+	}, paths)
+}
+
+func TestSnapshotInspectSizes(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/snapshots/vfs/snap-000":
+			writeJSON(w, 200, `{"id":"snap-000","volumeID":"vfs-000",`+
+				`"sizeGiB":8,"deltaBytes":1048576}`)
+		case "/snapshots/vfs/snap-001":
+			writeJSON(w, 200, `{"id":"snap-001","sizeGiB":8}`)
+		default:
+			writeError(w, 501, "not implemented")
+		}
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	snap, err := c.SnapshotInspect(ctx, "vfs", "snap-000")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 8, snap.SizeGiB)
+	if assert.NotNil(t, snap.DeltaBytes) {
+		assert.EqualValues(t, 1048576, *snap.DeltaBytes)
+	}
+
+	buf, err := json.Marshal(snap)
+	assert.NoError(t, err)
+	var decoded types.Snapshot
+	assert.NoError(t, json.Unmarshal(buf, &decoded))
+	assert.Equal(t, snap, &decoded)
+
+	snap, err = c.SnapshotInspect(ctx, "vfs", "snap-001")
+	assert.NoError(t, err)
+	assert.Nil(t, snap.DeltaBytes)
+
+	_, err = c.SnapshotInspect(ctx, "scaleio", "snap-000")
+	assertCapabilityNotImplemented(t, err, "SnapshotInspect", "snapshot")
+}
+
+func assertCapabilityNotImplemented(
+	t *testing.T, err error, operation, capability string) {
+
+	assert.True(t, errors.Is(err, types.ErrNotImplemented))
+	assert.EqualError(t, err, "capability not implemented: "+capability)
+	if assert.IsType(t, &types.ErrCapabilityNotImplemented{}, err) {
+		cerr := err.(*types.ErrCapabilityNotImplemented)
+		assert.Equal(t, operation, cerr.Operation())
+		assert.Equal(t, capability, cerr.Capability())
+		assert.Equal(t, "scaleio", cerr.Fields()["service"])
+		assert.Equal(t, "not implemented", cerr.Fields()["detail"])
+	}
+}
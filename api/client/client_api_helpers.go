@@ -0,0 +1,220 @@
+package client
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/goof"
+
+	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+)
+
+var (
+	// waitForServiceBackoff is the initial wait between service polls.
+	waitForServiceBackoff = 100 * time.Millisecond
+
+	// waitForServiceMaxBackoff is the maximum wait between service polls.
+	waitForServiceMaxBackoff = 5 * time.Second
+)
+
+func (c *client) WaitForService(
+	ctx types.Context, name string) (*types.ServiceInfo, error) {
+
+	backoff := waitForServiceBackoff
+
+	for {
+		si, err := c.ServiceInspect(ctx, name)
+		if err == nil {
+			return si, nil
+		}
+		if httpStatus(err) != http.StatusServiceUnavailable {
+			return nil, err
+		}
+
+		ctx.WithField("backoff", backoff).Debug("waiting on service")
+
+		select {
+		case <-ctx.Done():
+			return nil, newCanceledError(ctx)
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > waitForServiceMaxBackoff {
+			backoff = waitForServiceMaxBackoff
+		}
+	}
+}
+
+// volumeLessFuncs are the functions used to sort volumes, keyed by the name
+// of the volume field by which they sort.
+var volumeLessFuncs = map[string]func(i, j *types.VolumeWithService) bool{
+	"name": func(i, j *types.VolumeWithService) bool {
+		return i.Name < j.Name
+	},
+	"size": func(i, j *types.VolumeWithService) bool {
+		return i.Size < j.Size
+	},
+	"created": func(i, j *types.VolumeWithService) bool {
+		return i.CreatedTime < j.CreatedTime
+	},
+}
+
+type volumesWithService struct {
+	vols []*types.VolumeWithService
+	less func(i, j *types.VolumeWithService) bool
+}
+
+func (v *volumesWithService) Len() int {
+	return len(v.vols)
+}
+
+func (v *volumesWithService) Swap(i, j int) {
+	v.vols[i], v.vols[j] = v.vols[j], v.vols[i]
+}
+
+func (v *volumesWithService) Less(i, j int) bool {
+	vi, vj := v.vols[i], v.vols[j]
+	if v.less(vi, vj) {
+		return true
+	}
+	if v.less(vj, vi) {
+		return false
+	}
+	if vi.ID != vj.ID {
+		return vi.ID < vj.ID
+	}
+	return vi.Service < vj.Service
+}
+
+func (c *client) SortedVolumes(
+	ctx types.Context, by string) ([]*types.VolumeWithService, error) {
+
+	less, ok := volumeLessFuncs[strings.ToLower(by)]
+	if !ok {
+		return nil, goof.WithField("by", by, "invalid sort key")
+	}
+
+	svm, err := c.Volumes(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := &volumesWithService{less: less}
+	for service, vm := range svm {
+		for _, v := range vm {
+			sorted.vols = append(
+				sorted.vols, &types.VolumeWithService{Volume: v, Service: service})
+		}
+	}
+	sort.Sort(sorted)
+
+	return sorted.vols, nil
+}
+
+func (c *client) ResolveVolume(
+	ctx types.Context, name string) (string, string, error) {
+
+	svm, err := c.Volumes(ctx, false)
+	if err != nil {
+		return "", "", err
+	}
+	name = c.foldVolumeName(name)
+
+	var (
+		service  string
+		volumeID string
+		matches  int
+		services []string
+	)
+
+	for s, vm := range svm {
+		matched := false
+		for _, v := range vm {
+			if v.Name != name {
+				continue
+			}
+			service, volumeID = s, v.ID
+			matches++
+			matched = true
+		}
+		if matched {
+			services = append(services, s)
+		}
+	}
+
+	switch matches {
+	case 0:
+		return "", "", utils.NewNotFoundError(name)
+	case 1:
+		return service, volumeID, nil
+	}
+
+	sort.Strings(services)
+	return "", "", utils.NewAmbiguousVolumeError(name, services)
+}
+
+func (c *client) VolumeStateCounts(
+	ctx types.Context, service string) (map[types.VolumeState]int, error) {
+
+	// the attachments are required to count volumes whose driver does not
+	// report an attached status
+	vols, err := c.VolumesByService(ctx, service, true)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[types.VolumeState]int{}
+	for _, v := range vols {
+		counts[v.State()]++
+	}
+	return counts, nil
+}
+
+func (c *client) VolumeAttachedTo(
+	ctx types.Context, service, volumeID string) ([]string, error) {
+
+	vol, err := c.VolumeInspect(ctx, service, volumeID, true)
+	if err != nil {
+		return nil, err
+	}
+	if vol == nil {
+		return nil, utils.NewNotFoundError(volumeID)
+	}
+
+	// a volume may have more than one attachment to the same instance
+	instanceIDs := []string{}
+	seen := map[string]bool{}
+	for _, a := range vol.Attachments {
+		if a.InstanceID == nil || a.InstanceID.ID == "" ||
+			seen[a.InstanceID.ID] {
+			continue
+		}
+		seen[a.InstanceID.ID] = true
+		instanceIDs = append(instanceIDs, a.InstanceID.ID)
+	}
+	return instanceIDs, nil
+}
+
+func (c *client) VolumesCreateChan(
+	ctx types.Context,
+	service string,
+	requests []*types.VolumeCreateRequest) <-chan *types.VolumeCreateResult {
+
+	return utils.VolumesCreateChan(ctx, c, service, requests,
+		c.config.GetInt(types.ConfigClientBulkConcurrency))
+}
+
+func (c *client) Reconcile(
+	ctx types.Context,
+	service string,
+	desired []*types.VolumeCreateRequest,
+	opts *types.ReconcileOpts) (*types.ReconcileResult, error) {
+
+	if c.nameCaseFold {
+		desired = utils.FoldVolumeNames(desired)
+	}
+	return utils.Reconcile(ctx, c, service, desired, opts)
+}
@@ -0,0 +1,350 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestWaitForService(t *testing.T) {
+
+	defer func(d time.Duration) { waitForServiceBackoff = d }(
+		waitForServiceBackoff)
+	waitForServiceBackoff = time.Millisecond
+	attempts := 0
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			writeError(w, 503, "service initializing")
+			return
+		}
+		writeJSON(w, 200, `{"name":"vfs","driver":{"name":"vfs"}}`)
+	})
+	defer server.Close()
+
+	si, err := c.WaitForService(context.Background(), "vfs")
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs", si.Name)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestWaitForServiceCancel(t *testing.T) {
+
+	defer func(d time.Duration) { waitForServiceBackoff = d }(
+		waitForServiceBackoff)
+	waitForServiceBackoff = time.Millisecond
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 503, "service initializing")
+	})
+	defer server.Close()
+
+	goCtx, cancel := gocontext.WithTimeout(
+		gocontext.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	si, err := c.WaitForService(context.New(goCtx), "vfs")
+	assert.Nil(t, si)
+	assert.True(t, errors.Is(err, gocontext.DeadlineExceeded))
+}
+
+func TestWaitForServiceError(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 404, "resource not found")
+	})
+	defer server.Close()
+
+	_, err := c.WaitForService(context.Background(), "vfs")
+	assert.Error(t, err)
+}
+
+func TestSortedVolumes(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, `{
+			"vfs": {
+				"vfs-002": {"id":"vfs-002","name":"b","size":10,"createdTime":300},
+				"vfs-001": {"id":"vfs-001","name":"c","size":20,"createdTime":100}
+			},
+			"scaleio": {
+				"sio-001": {"id":"sio-001","name":"a","size":20,"createdTime":200},
+				"sio-000": {"id":"sio-000","name":"b","size":5,"createdTime":200}
+			}
+		}`)
+	})
+	defer server.Close()
+
+	ids := func(vols []*types.VolumeWithService) []string {
+		var ids []string
+		for _, v := range vols {
+			ids = append(ids, v.ID)
+		}
+		return ids
+	}
+
+	ctx := context.Background()
+
+	vols, err := c.SortedVolumes(ctx, "name")
+	assert.NoError(t, err)
+	assert.Equal(t,
+		[]string{"sio-001", "sio-000", "vfs-002", "vfs-001"}, ids(vols))
+	assert.Equal(t, "scaleio", vols[0].Service)
+	assert.Equal(t, "vfs", vols[3].Service)
+
+	vols, err = c.SortedVolumes(ctx, "size")
+	assert.NoError(t, err)
+	assert.Equal(t,
+		[]string{"sio-000", "vfs-002", "sio-001", "vfs-001"}, ids(vols))
+
+	vols, err = c.SortedVolumes(ctx, "created")
+	assert.NoError(t, err)
+	assert.Equal(t,
+		[]string{"vfs-001", "sio-000", "sio-001", "vfs-002"}, ids(vols))
+
+	_, err = c.SortedVolumes(ctx, "status")
+	assert.EqualError(t, err, "invalid sort key")
+}
+
+func TestResolveVolume(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, `{
+			"vfs": {
+				"vfs-000": {"id":"vfs-000","name":"data"},
+				"vfs-001": {"id":"vfs-001","name":"logs"}
+			},
+			"scaleio": {
+				"sio-000": {"id":"sio-000","name":"data"}
+			}
+		}`)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	service, volumeID, err := c.ResolveVolume(ctx, "logs")
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs", service)
+	assert.Equal(t, "vfs-001", volumeID)
+
+	_, _, err = c.ResolveVolume(ctx, "data")
+	if assert.IsType(t, &types.ErrAmbiguousVolume{}, err) {
+		assert.Contains(t, err.Error(), "scaleio")
+		assert.Contains(t, err.Error(), "vfs")
+		assert.Equal(t,
+			[]string{"scaleio", "vfs"},
+			err.(*types.ErrAmbiguousVolume).Fields()["services"])
+	}
+
+	_, _, err = c.ResolveVolume(ctx, "backups")
+	assert.IsType(t, &types.ErrNotFound{}, err)
+}
+
+func TestVolumeAttachedTo(t *testing.T) {
+
+	var query string
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		switch r.URL.Path {
+		case "/volumes/vfs/vfs-000":
+			writeJSON(w, 200, `{"id":"vfs-000","attachments":[
+				{"volumeID":"vfs-000","instanceID":{"id":"iid-000"}},
+				{"volumeID":"vfs-000","instanceID":{"id":"iid-001"}}]}`)
+		case "/volumes/vfs/vfs-001":
+			writeJSON(w, 200, `{"id":"vfs-001"}`)
+		default:
+			writeError(w, 404, "resource not found")
+		}
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	instanceIDs, err := c.VolumeAttachedTo(ctx, "vfs", "vfs-000")
+	assert.NoError(t, err)
+	assert.Equal(t, "attachments=true", query)
+	assert.Equal(t, []string{"iid-000", "iid-001"}, instanceIDs)
+
+	instanceIDs, err = c.VolumeAttachedTo(ctx, "vfs", "vfs-001")
+	assert.NoError(t, err)
+	assert.NotNil(t, instanceIDs)
+	assert.Empty(t, instanceIDs)
+
+	_, err = c.VolumeAttachedTo(ctx, "vfs", "vfs-999")
+	assert.Equal(t, 404, httpStatus(err))
+}
+
+func TestVolumeStateCounts(t *testing.T) {
+
+	var query string
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		writeJSON(w, 200, `{
+			"vfs-000": {"id":"vfs-000","status":"available"},
+			"vfs-001": {"id":"vfs-001"},
+			"vfs-002": {"id":"vfs-002","status":"In-Use"},
+			"vfs-003": {"id":"vfs-003","attachments":[
+				{"volumeID":"vfs-003","instanceID":{"id":"iid-000"}}]},
+			"vfs-004": {"id":"vfs-004","status":"error"},
+			"vfs-005": {"id":"vfs-005","status":"migrating"}
+		}`)
+	})
+	defer server.Close()
+
+	counts, err := c.VolumeStateCounts(context.Background(), "vfs")
+	assert.NoError(t, err)
+	assert.Equal(t, "attachments=true", query)
+	assert.Equal(t, map[types.VolumeState]int{
+		types.VolumeStateAvailable: 2,
+		types.VolumeStateAttached:  2,
+		types.VolumeStateError:     1,
+		types.VolumeStateUnknown:   1,
+	}, counts)
+}
+
+func TestVolumesCreateChan(t *testing.T) {
+
+	var (
+		inProgress    int32
+		maxInProgress int32
+	)
+	release := make(chan struct{})
+
+	config := gofig.New()
+	config.Set(types.ConfigClientBulkConcurrency, 2)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&inProgress, 1)
+			defer atomic.AddInt32(&inProgress, -1)
+			for {
+				max := atomic.LoadInt32(&maxInProgress)
+				if n <= max ||
+					atomic.CompareAndSwapInt32(&maxInProgress, max, n) {
+					break
+				}
+			}
+
+			var request types.VolumeCreateRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			switch request.Name {
+			case "v0":
+				<-release
+				writeJSON(w, 200, `{"id":"vfs-v0"}`)
+			case "v1":
+				writeError(w, 400, "invalid name")
+			default:
+				writeJSON(w, 200, `{"id":"vfs-`+request.Name+`"}`)
+			}
+		})
+	defer server.Close()
+
+	results := c.VolumesCreateChan(
+		context.Background(), "vfs", []*types.VolumeCreateRequest{
+			{Name: "v0"}, {Name: "v1"}, {Name: "v2"}, {Name: "v3"},
+		})
+
+	// the results of the later requests arrive while the first request is
+	// in progress
+	byIndex := map[int]*types.VolumeCreateResult{}
+	for i := 0; i < 3; i++ {
+		select {
+		case r := <-results:
+			byIndex[r.Index] = r
+		case <-time.After(time.Second):
+			t.Fatal("results not received before the first completed")
+		}
+	}
+	assert.NotContains(t, byIndex, 0)
+	if assert.Contains(t, byIndex, 1) {
+		assert.EqualError(t, byIndex[1].Error, "invalid name")
+		assert.Nil(t, byIndex[1].Volume)
+	}
+	if assert.Contains(t, byIndex, 3) {
+		assert.NoError(t, byIndex[3].Error)
+		assert.Equal(t, "vfs-v3", byIndex[3].Volume.ID)
+	}
+
+	close(release)
+	r := <-results
+	assert.Equal(t, 0, r.Index)
+	assert.NoError(t, r.Error)
+	assert.Equal(t, "vfs-v0", r.Volume.ID)
+
+	_, ok := <-results
+	assert.False(t, ok)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxInProgress))
+}
+
+func TestReconcile(t *testing.T) {
+
+	var (
+		created []string
+		removed []string
+	)
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			var request types.VolumeCreateRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			created = append(created, request.Name)
+			writeJSON(w, 200, `{"id":"vfs-`+request.Name+`",`+
+				`"name":"`+request.Name+`"}`)
+		case http.MethodDelete:
+			removed = append(removed, r.URL.Path)
+			writeJSON(w, 200, `{}`)
+		default:
+			writeJSON(w, 200, `{
+				"vfs-000": {"id": "vfs-000", "name": "a"},
+				"vfs-001": {"id": "vfs-001", "name": "b"},
+				"vfs-002": {"id": "vfs-002", "name": "c"}
+			}`)
+		}
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+	desired := []*types.VolumeCreateRequest{
+		{Name: "a"}, {Name: "d"}, {Name: "b"},
+	}
+
+	result, err := c.Reconcile(ctx, "vfs", desired, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d"}, created)
+	assert.Nil(t, removed)
+	if assert.Len(t, result.Created, 1) {
+		assert.Equal(t, "vfs-d", result.Created[0].ID)
+	}
+	assert.Len(t, result.Unchanged, 2)
+	assert.Empty(t, result.Removed)
+
+	created = nil
+	result, err = c.Reconcile(ctx, "vfs",
+		desired, &types.ReconcileOpts{Prune: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d"}, created)
+	assert.Equal(t, []string{"/volumes/vfs/vfs-002"}, removed)
+	if assert.Len(t, result.Removed, 1) {
+		assert.Equal(t, "c", result.Removed[0].Name)
+	}
+
+	_, err = c.Reconcile(ctx, "vfs",
+		[]*types.VolumeCreateRequest{{Name: "a"}, {Name: "a"}}, nil)
+	assert.EqualError(t, err, "duplicate desired volume")
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+const (
+	auditOutcomeSuccess = "success"
+	auditOutcomeFailure = "failure"
+)
+
+// fileAuditor is a types.Auditor that appends each audit record to a file as
+// a line of JSON.
+type fileAuditor struct {
+	sync.Mutex
+	path string
+}
+
+func newFileAuditor(path string) *fileAuditor {
+	return &fileAuditor{path: path}
+}
+
+func (a *fileAuditor) Audit(record *types.AuditRecord) error {
+
+	a.Lock()
+	defer a.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(record)
+}
+
+// audit sends a record of a mutating operation to the client's auditor. A
+// failure to write the record is logged but does not fail the operation.
+func (c *client) audit(
+	ctx types.Context,
+	operation, service, volumeID, snapshotID string,
+	opErr error) {
+
+	if c.auditor == nil {
+		return
+	}
+
+	record := &types.AuditRecord{
+		Time:       time.Now().UTC(),
+		Operation:  operation,
+		Service:    service,
+		VolumeID:   volumeID,
+		SnapshotID: snapshotID,
+		Outcome:    auditOutcomeSuccess,
+	}
+
+	if user, ok := ctx.Value(context.UserKey).(string); ok {
+		record.Principal = user
+	}
+
+	if opErr != nil {
+		record.Outcome = auditOutcomeFailure
+		record.Error = opErr.Error()
+	}
+
+	if err := c.auditor.Audit(record); err != nil {
+		ctx.WithError(err).Warn("error writing audit record")
+	}
+}
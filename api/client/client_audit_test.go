@@ -0,0 +1,105 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestAuditFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "libstorage-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	auditFile := path.Join(dir, "audit.log")
+
+	config := gofig.New()
+	config.Set(types.ConfigClientAuditFile, auditFile)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+		})
+	defer server.Close()
+
+	ctx := context.Background().WithValue(context.UserKey, "akutz")
+
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	_, err = os.Stat(auditFile)
+	assert.True(t, os.IsNotExist(err))
+
+	vol, err := c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-000", vol.ID)
+
+	f, err := os.Open(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []*types.AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := &types.AuditRecord{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), record))
+		records = append(records, record)
+	}
+	assert.NoError(t, scanner.Err())
+
+	if !assert.Len(t, records, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, "VolumeCreate", records[0].Operation)
+	assert.Equal(t, "vfs", records[0].Service)
+	assert.Equal(t, "vfs-000", records[0].VolumeID)
+	assert.Equal(t, "akutz", records[0].Principal)
+	assert.Equal(t, "success", records[0].Outcome)
+	assert.Empty(t, records[0].Error)
+	assert.False(t, records[0].Time.IsZero())
+}
+
+type testAuditor struct {
+	records []*types.AuditRecord
+}
+
+func (a *testAuditor) Audit(record *types.AuditRecord) error {
+	a.records = append(a.records, record)
+	return nil
+}
+
+func TestAuditFailure(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 500, "remove failed")
+	})
+	defer server.Close()
+
+	auditor := &testAuditor{}
+	c.Auditor(auditor)
+
+	err := c.VolumeRemove(context.Background(), "vfs", "vfs-000")
+	assert.Error(t, err)
+
+	if !assert.Len(t, auditor.records, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, "VolumeRemove", auditor.records[0].Operation)
+	assert.Equal(t, "vfs-000", auditor.records[0].VolumeID)
+	assert.Equal(t, "failure", auditor.records[0].Outcome)
+	assert.Equal(t, "remove failed", auditor.records[0].Error)
+}
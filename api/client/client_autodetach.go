@@ -0,0 +1,93 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+// attachment is a volume attached by the client.
+type attachment struct {
+	service  string
+	volumeID string
+
+	// iid is the instance to which the volume was attached, if known.
+	iid *types.InstanceID
+}
+
+func attachmentKey(service, volumeID string) string {
+	return fmt.Sprintf("%s/%s", service, volumeID)
+}
+
+// trackAttachment records that the client attached the volume to the
+// instance in the context if volumes are detached when the client is closed.
+func (c *client) trackAttachment(
+	ctx types.Context, service, volumeID string) {
+
+	if !c.autoDetach {
+		return
+	}
+
+	a := &attachment{service: service, volumeID: volumeID}
+	if iid, ok := context.InstanceID(ctx); ok {
+		a.iid = iid
+	}
+
+	c.attachmentsRWL.Lock()
+	defer c.attachmentsRWL.Unlock()
+	c.attachments[attachmentKey(service, volumeID)] = a
+}
+
+// untrackAttachments removes the records of the volumes detached from the
+// service. An empty volume ID removes the records of all of the service's
+// volumes, and an empty service removes all records.
+func (c *client) untrackAttachments(service, volumeID string) {
+
+	if !c.autoDetach {
+		return
+	}
+
+	c.attachmentsRWL.Lock()
+	defer c.attachmentsRWL.Unlock()
+
+	if volumeID != "" {
+		delete(c.attachments, attachmentKey(service, volumeID))
+		return
+	}
+	for k, a := range c.attachments {
+		if service == "" || a.service == service {
+			delete(c.attachments, k)
+		}
+	}
+}
+
+func (c *client) Close(ctx types.Context) error {
+
+	if c.autoDetach {
+		c.attachmentsRWL.Lock()
+		attachments := c.attachments
+		c.attachments = map[string]*attachment{}
+		c.attachmentsRWL.Unlock()
+
+		for _, a := range attachments {
+			ctx := ctx
+			if a.iid != nil {
+				ctx = ctx.WithValue(context.InstanceIDKey, a.iid)
+			}
+			if _, err := c.VolumeDetach(
+				ctx, a.service, a.volumeID,
+				&types.VolumeDetachRequest{}); err != nil {
+				ctx.WithFields(map[string]interface{}{
+					"service":  a.service,
+					"volumeID": a.volumeID,
+				}).WithError(err).Warn("error detaching volume on close")
+			}
+		}
+	}
+
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func newAutoDetachTestClient(
+	t *testing.T,
+	autoDetach bool,
+	detached *[]string) (*client, func()) {
+
+	var mu sync.Mutex
+
+	config := gofig.New()
+	config.Set(types.ConfigClientAutoDetachOnClose, autoDetach)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.RawQuery {
+			case "attach":
+				writeJSON(w, 200, `{"volume":{"id":"vfs-000"}}`)
+			case "detach":
+				mu.Lock()
+				*detached = append(*detached, r.URL.Path+" "+
+					r.Header.Get(types.InstanceIDHeader))
+				mu.Unlock()
+				if r.URL.Path == "/volumes/vfs/vfs-002" {
+					writeError(w, 500, "detach failed")
+					return
+				}
+				writeJSON(w, 200, `{"id":"vfs-000"}`)
+			default:
+				writeJSON(w, 200, `{"id":"vfs-000"}`)
+			}
+		})
+	return c, server.Close
+}
+
+func TestAutoDetachOnClose(t *testing.T) {
+
+	var detached []string
+	c, closeServer := newAutoDetachTestClient(t, true, &detached)
+	defer closeServer()
+
+	ctx := context.Background().WithValue(
+		context.InstanceIDKey, &types.InstanceID{ID: "iid-000", Driver: "vfs"})
+
+	for _, volumeID := range []string{"vfs-000", "vfs-001", "vfs-002"} {
+		_, _, err := c.VolumeAttach(ctx, "vfs", volumeID, nil)
+		assert.NoError(t, err)
+	}
+	_, err := c.VolumeDetach(ctx, "vfs", "vfs-001", nil)
+	assert.NoError(t, err)
+	detached = nil
+
+	// the failure to detach vfs-002 does not fail the close
+	assert.NoError(t, c.Close(context.Background()))
+	sort.Strings(detached)
+	assert.Len(t, detached, 2)
+	for i, volumeID := range []string{"vfs-000", "vfs-002"} {
+		assert.Contains(t, detached[i], "/volumes/vfs/"+volumeID+" ")
+		assert.Contains(t, detached[i], "iid-000")
+	}
+
+	// the attachments are detached once
+	detached = nil
+	assert.NoError(t, c.Close(context.Background()))
+	assert.Empty(t, detached)
+}
+
+func TestAutoDetachOnCloseDisabled(t *testing.T) {
+
+	var detached []string
+	c, closeServer := newAutoDetachTestClient(t, false, &detached)
+	defer closeServer()
+
+	_, _, err := c.VolumeAttach(context.Background(), "vfs", "vfs-000", nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Close(context.Background()))
+	assert.Empty(t, detached)
+}
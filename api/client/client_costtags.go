@@ -0,0 +1,38 @@
+package client
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+)
+
+var (
+	costTagKeyRX   = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,63}$`)
+	costTagValueRX = regexp.MustCompile(`^[A-Za-z0-9_.:/@+= -]{0,255}$`)
+)
+
+// withCostTagsHeader returns a copy of the context with the context's cost
+// allocation tags, if any, encoded as the value of the cost tags header. An
+// error is returned if a tag's key or value contains invalid characters.
+func withCostTagsHeader(ctx types.Context) (types.Context, error) {
+
+	tags, ok := context.CostTags(ctx)
+	if !ok || len(tags) == 0 {
+		return ctx, nil
+	}
+
+	for k, v := range tags {
+		if !costTagKeyRX.MatchString(k) || !costTagValueRX.MatchString(v) {
+			return nil, utils.NewInvalidCostTagError(k, v)
+		}
+	}
+
+	buf, err := json.Marshal(tags)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.WithValue(costTagsHeaderKey, string(buf)), nil
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestCostTags(t *testing.T) {
+
+	var header []string
+	requests := 0
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		header = r.Header[types.CostTagsHeader]
+		writeJSON(w, 200, `{}`)
+	})
+	defer server.Close()
+
+	ctx := context.WithCostTags(context.Background(), map[string]string{
+		"team":    "storage-eng",
+		"cost.id": "cc/1234",
+	})
+	_, err := c.Services(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		[]string{`{"cost.id":"cc/1234","team":"storage-eng"}`}, header)
+
+	_, err = c.Services(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, header)
+	assert.Equal(t, 2, requests)
+
+	for _, tags := range []map[string]string{
+		{"team name": "storage"},
+		{"": "storage"},
+		{"team": "storage\r\nX-Injected: 1"},
+		{"team": `"storage"`},
+	} {
+		_, err = c.Services(context.WithCostTags(context.Background(), tags))
+		assert.IsType(t, &types.ErrInvalidCostTag{}, err)
+	}
+	assert.Equal(t, 2, requests)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"regexp"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+)
+
+var partitionRXs = []*regexp.Regexp{
+	// multipath and by-id partitions, ex. /dev/mapper/mpatha-part1
+	regexp.MustCompile(`^(.+)-part\d+$`),
+	// partitions of disks whose names end in a digit, ex. /dev/nvme0n1p1
+	regexp.MustCompile(`^(.*\d)p\d+$`),
+	// partitions of lettered disks, ex. /dev/xvdb1 or /dev/mapper/mpatha1
+	regexp.MustCompile(`^(.*/(?:s|v|xv|h)d[a-z]+)\d+$`),
+	regexp.MustCompile(`^(/dev/mapper/mpath[a-z]+)\d+$`),
+}
+
+// diskName returns the name of the disk to which the provided device name
+// belongs, removing the partition suffix if there is one.
+func diskName(deviceName string) string {
+	for _, rx := range partitionRXs {
+		if m := rx.FindStringSubmatch(deviceName); len(m) > 1 {
+			return m[1]
+		}
+	}
+	return deviceName
+}
+
+// checkDeviceNotInUse returns an ErrDeviceInUse error if the provided device
+// name, or a partition of the same disk, is one of the local devices in the
+// context.
+func checkDeviceNotInUse(ctx types.Context, deviceName string) error {
+
+	var ldm types.LocalDevicesMap
+	if lds, ok := context.LocalDevices(ctx); ok {
+		ldm = types.LocalDevicesMap{lds.Driver: lds}
+	} else if v, ok := ctx.Value(
+		context.AllLocalDevicesKey).(types.LocalDevicesMap); ok {
+		ldm = v
+	}
+
+	disk := diskName(deviceName)
+	for _, lds := range ldm {
+		if lds == nil {
+			continue
+		}
+		for localDevice := range lds.DeviceMap {
+			if diskName(localDevice) == disk {
+				return utils.NewDeviceInUseError(deviceName, localDevice)
+			}
+		}
+	}
+	return nil
+}
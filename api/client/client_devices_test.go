@@ -0,0 +1,82 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestDiskName(t *testing.T) {
+	for deviceName, disk := range map[string]string{
+		"/dev/xvdb":                   "/dev/xvdb",
+		"/dev/xvdb1":                  "/dev/xvdb",
+		"/dev/sdaa12":                 "/dev/sdaa",
+		"/dev/nvme0n1":                "/dev/nvme0n1",
+		"/dev/nvme0n1p2":              "/dev/nvme0n1",
+		"/dev/mapper/mpatha":          "/dev/mapper/mpatha",
+		"/dev/mapper/mpatha1":         "/dev/mapper/mpatha",
+		"/dev/mapper/mpatha-part1":    "/dev/mapper/mpatha",
+		"/dev/mapper/3600a0b80005ad":  "/dev/mapper/3600a0b80005ad",
+		"/dev/mapper/3600a0b80005adp": "/dev/mapper/3600a0b80005adp",
+	} {
+		assert.Equal(t, disk, diskName(deviceName), deviceName)
+	}
+}
+
+func TestVolumeAttachDeviceInUse(t *testing.T) {
+
+	var attaches int
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			attaches++
+			writeJSON(w, 200, `{"volume":{"id":"vfs-000"},"attachToken":"t0"}`)
+			return
+		}
+		writeJSON(w, 200, `{"id":"vfs-000"}`)
+	})
+	defer server.Close()
+
+	ctx := context.Background().WithValue(
+		context.LocalDevicesKey, &types.LocalDevices{
+			Driver: "vfs",
+			DeviceMap: map[string]string{
+				"/dev/xvda":                "",
+				"/dev/xvdb1":               "/mnt/b",
+				"/dev/mapper/mpatha-part1": "/mnt/mpatha",
+			},
+		})
+
+	attach := func(deviceName string) error {
+		_, _, err := c.VolumeAttach(ctx, "vfs", "vfs-000",
+			&types.VolumeAttachRequest{NextDeviceName: &deviceName})
+		return err
+	}
+
+	for _, deviceName := range []string{
+		"/dev/xvda", "/dev/xvdb", "/dev/mapper/mpatha"} {
+		err := attach(deviceName)
+		_, ok := err.(*types.ErrDeviceInUse)
+		assert.True(t, ok, deviceName)
+	}
+	assert.Equal(t, 0, attaches)
+
+	assert.NoError(t, attach("/dev/xvdc"))
+	assert.Equal(t, 1, attaches)
+
+	// the local devices of all services are checked
+	ctx = context.Background().WithValue(
+		context.AllLocalDevicesKey, types.LocalDevicesMap{
+			"vfs": &types.LocalDevices{
+				Driver:    "vfs",
+				DeviceMap: map[string]string{"/dev/xvdd": ""},
+			},
+		})
+	err := attach("/dev/xvdd1")
+	assert.EqualError(t, err, "device in use")
+	assert.Equal(t, 1, attaches)
+}
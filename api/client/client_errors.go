@@ -0,0 +1,132 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/akutz/goof"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+)
+
+// driverError is a types.DriverError. The server responds with a 500 for
+// errors other than those defined by libStorage, and a 500 whose error has a
+// code is an error returned by a storage driver.
+type driverError struct {
+	*types.HTTPError
+	code string
+}
+
+func newDriverError(err *types.HTTPError) *driverError {
+	code, _ := err.Fields()["code"].(string)
+	return &driverError{HTTPError: err, code: code}
+}
+
+func (e *driverError) DriverError() {}
+
+func (e *driverError) Code() string {
+	return e.code
+}
+
+// Unwrap returns the *types.HTTPError with the response's status and body.
+func (e *driverError) Unwrap() error {
+	return e.HTTPError
+}
+
+// maxErrorBodySize is the maximum number of bytes of an error response's body
+// that are read.
+const maxErrorBodySize = 64 * 1024
+
+// newHTTPError returns a *types.HTTPError for the error decoded from the
+// response body.
+func newHTTPError(err goof.HTTPError, body []byte) *types.HTTPError {
+	return &types.HTTPError{
+		HTTPError:  err,
+		StatusCode: err.Status(),
+		Message:    err.Error(),
+		Body:       body,
+	}
+}
+
+// newValidationError returns a *types.ValidationError if the error's fields
+// include a list of field errors, otherwise nil is returned.
+func newValidationError(err *types.HTTPError) *types.ValidationError {
+	items, ok := err.Fields()["fieldErrors"].([]interface{})
+	if !ok || len(items) == 0 {
+		return nil
+	}
+	verr := &types.ValidationError{HTTPError: err}
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		field, _ := m["field"].(string)
+		message, _ := m["message"].(string)
+		verr.Errors = append(verr.Errors, types.FieldError{
+			Field:   field,
+			Message: message,
+		})
+	}
+	return verr
+}
+
+// newCanceledError returns a *types.ErrCanceled with the reason the context
+// was cancelled.
+func newCanceledError(ctx types.Context) error {
+	err := ctx.Err()
+	if err == gocontext.DeadlineExceeded {
+		return utils.NewCanceledError(types.CancelReasonDeadline, err)
+	}
+	return utils.NewCanceledError(types.CancelReasonCaller, err)
+}
+
+// notImplemented returns an ErrCapabilityNotImplemented error naming the
+// capability the operation requires if the server responded to it with a
+// 501, otherwise the error is returned as is.
+func notImplemented(
+	err error, service, operation, capability string) error {
+
+	if httpStatus(err) != http.StatusNotImplemented {
+		return err
+	}
+	var detail string
+	if herr, ok := err.(goof.HTTPError); ok {
+		detail = herr.Error()
+	}
+	return utils.NewCapabilityNotImplementedError(
+		service, operation, capability, detail)
+}
+
+// transportError is a types.TransportError.
+type transportError struct {
+	error
+	types.RetryHistory
+	url string
+}
+
+// Unwrap returns the error that caused the request to fail.
+func (e *transportError) Unwrap() error {
+	return e.error
+}
+
+// Fields returns the URL of the request that failed.
+func (e *transportError) Fields() map[string]interface{} {
+	return map[string]interface{}{"url": e.url}
+}
+
+// Temporary always returns true; a failure to communicate with the server
+// does not mean the operation itself failed, so it may be retried.
+func (e *transportError) Temporary() bool {
+	return true
+}
+
+func (e *transportError) Timeout() bool {
+	if terr, ok := e.error.(interface {
+		Timeout() bool
+	}); ok {
+		return terr.Timeout()
+	}
+	return false
+}
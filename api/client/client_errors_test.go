@@ -0,0 +1,224 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/akutz/goof"
+	"github.com/stretchr/testify/assert"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestDriverError(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/volumes/vfs/vfs-000" {
+			writeErrorCode(w, 500, "InvalidParameterValue", "request rejected")
+			return
+		}
+		writeError(w, 404, "resource not found")
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	if assert.Implements(t, (*types.DriverError)(nil), err) {
+		derr := err.(types.DriverError)
+		assert.Equal(t, "InvalidParameterValue", derr.Code())
+		assert.EqualError(t, derr, "request rejected")
+	}
+	_, ok := err.(types.TransportError)
+	assert.False(t, ok)
+	if assert.Implements(t, (*goof.HTTPError)(nil), err) {
+		assert.Equal(t, 500, err.(goof.HTTPError).Status())
+	}
+
+	// the driver error carries the response's status, message, and body
+	derr := err.(*driverError)
+	assert.Equal(t, 500, derr.HTTPError.StatusCode)
+	assert.Equal(t, "request rejected", derr.HTTPError.Message)
+	assert.Contains(t, string(derr.HTTPError.Body), "InvalidParameterValue")
+	var herr *types.HTTPError
+	if assert.True(t, errors.As(err, &herr)) {
+		assert.True(t, herr == derr.HTTPError)
+	}
+
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.Error(t, err)
+	_, ok = err.(types.DriverError)
+	assert.False(t, ok)
+	_, ok = err.(types.TransportError)
+	assert.False(t, ok)
+	assert.Equal(t, 404, httpStatus(err))
+}
+
+func TestTransportError(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		writeJSON(w, 200, `{"id":"vfs-000"}`)
+	})
+
+	ctx := context.Background()
+
+	c.Client.Timeout = 10 * time.Millisecond
+	_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	if assert.Implements(t, (*types.TransportError)(nil), err) {
+		terr := err.(types.TransportError)
+		assert.True(t, terr.Temporary())
+		assert.True(t, terr.Timeout())
+	}
+	_, ok := err.(types.DriverError)
+	assert.False(t, ok)
+
+	server.Close()
+
+	c.Client.Timeout = 0
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	if assert.Implements(t, (*types.TransportError)(nil), err) {
+		terr := err.(types.TransportError)
+		assert.True(t, terr.Temporary())
+		assert.False(t, terr.Timeout())
+	}
+	_, ok = err.(types.DriverError)
+	assert.False(t, ok)
+}
+
+func TestErrorURL(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeErrorCode(w, 404, "ERR_NOT_FOUND", "resource not found")
+	})
+
+	ctx := context.Background()
+	url := "http://" + c.host + "/volumes/vfs/vfs-000?attachments=true"
+
+	_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", true)
+	if assert.Implements(t, (*goof.HTTPError)(nil), err) {
+		herr := err.(goof.HTTPError)
+		assert.Equal(t, url, herr.Fields()["url"])
+		assert.Equal(t, "ERR_NOT_FOUND", herr.Fields()["code"])
+		assert.Equal(t, 404, herr.Status())
+		assert.EqualError(t, herr, "resource not found")
+	}
+
+	server.Close()
+
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", true)
+	if assert.Implements(t, (*types.TransportError)(nil), err) {
+		ferr := err.(interface {
+			Fields() map[string]interface{}
+		})
+		assert.Equal(t, url, ferr.Fields()["url"])
+	}
+}
+
+func TestValidationError(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/volumes/vfs" {
+			buf, _ := json.Marshal(goof.NewHTTPError(goof.WithField(
+				"fieldErrors", []types.FieldError{
+					{Field: "name", Message: "name is required"},
+					{Field: "size", Message: "size must be positive"},
+				}, "invalid request"), 400))
+			writeJSON(w, 400, string(buf))
+			return
+		}
+		writeError(w, 400, "invalid request")
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := c.VolumeCreate(ctx, "vfs", &types.VolumeCreateRequest{})
+	verr, ok := err.(*types.ValidationError)
+	if assert.True(t, ok) {
+		assert.EqualError(t, verr, "invalid request")
+		assert.Equal(t, 400, verr.Status())
+		assert.Equal(t, []types.FieldError{
+			{Field: "name", Message: "name is required"},
+			{Field: "size", Message: "size must be positive"},
+		}, verr.FieldErrors())
+		assert.Equal(t, 400, verr.StatusCode)
+		assert.Contains(t, string(verr.Body), "name is required")
+		var herr *types.HTTPError
+		assert.True(t, errors.As(err, &herr))
+	}
+
+	_, err = c.VolumeCreate(ctx, "scaleio", &types.VolumeCreateRequest{})
+	assert.EqualError(t, err, "invalid request")
+	_, ok = err.(*types.ValidationError)
+	assert.False(t, ok)
+	assert.Equal(t, 400, httpStatus(err))
+}
+
+func TestCanceledError(t *testing.T) {
+
+	release := make(chan struct{})
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	defer server.Close()
+	defer close(release)
+
+	goCtx, cancel := gocontext.WithTimeout(
+		gocontext.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Root(context.New(goCtx))
+	assert.True(t, errors.Is(err, gocontext.DeadlineExceeded))
+	assert.False(t, errors.Is(err, gocontext.Canceled))
+	var cerr *types.ErrCanceled
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, types.CancelReasonDeadline, cerr.Reason)
+	}
+
+	goCtx, cancel = gocontext.WithCancel(gocontext.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = c.Root(context.New(goCtx))
+	assert.True(t, errors.Is(err, gocontext.Canceled))
+	assert.False(t, errors.Is(err, gocontext.DeadlineExceeded))
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, types.CancelReasonCaller, cerr.Reason)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/volumes" {
+			writeError(w, 503, "service unavailable")
+			return
+		}
+		w.WriteHeader(502)
+		w.Write([]byte("upstream unavailable"))
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	vols, err := c.Volumes(ctx, false)
+	assert.Nil(t, vols)
+	if assert.IsType(t, &types.HTTPError{}, err) {
+		herr := err.(*types.HTTPError)
+		assert.Equal(t, 503, herr.StatusCode)
+		assert.Equal(t, 503, herr.Status())
+		assert.Equal(t, "service unavailable", herr.Message)
+		assert.Contains(t, string(herr.Body), "service unavailable")
+	}
+
+	_, err = c.Root(ctx)
+	if assert.IsType(t, &types.HTTPError{}, err) {
+		herr := err.(*types.HTTPError)
+		assert.Equal(t, 502, herr.StatusCode)
+		assert.Equal(t, http.StatusText(502), herr.Message)
+		assert.Equal(t, "upstream unavailable", string(herr.Body))
+	}
+}
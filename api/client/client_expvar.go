@@ -0,0 +1,118 @@
+package client
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akutz/gofig"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+// expvarName is the name of the expvar map to which the client metrics are
+// published.
+const expvarName = "libstorage.client"
+
+var (
+	clientVarsOnce sync.Once
+	clientVars     *expvar.Map
+)
+
+// getClientVars returns the expvar map to which the client metrics are
+// published. The map is shared by all clients and is published the first time
+// this function is called.
+func getClientVars() *expvar.Map {
+	clientVarsOnce.Do(func() {
+		clientVars = expvar.NewMap(expvarName)
+	})
+	return clientVars
+}
+
+// initVars enables the publishing of the client's metrics via expvar if the
+// configuration indicates to do so. When a transport is provided, its dial
+// function is wrapped in order to count the number of open connections.
+func (c *client) initVars(config gofig.Config, transport *http.Transport) {
+
+	if config.GetBool(types.ConfigClientExpvar) {
+		c.vars = getClientVars()
+	}
+
+	if transport == nil {
+		return
+	}
+
+	dial := transport.DialContext
+	if dial == nil && transport.Dial != nil {
+		legacyDial := transport.Dial
+		dial = func(
+			_ gocontext.Context, network, addr string) (net.Conn, error) {
+			return legacyDial(network, addr)
+		}
+	}
+	if dial == nil {
+		dial = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+
+	transport.Dial = nil
+	transport.DialContext = func(
+		ctx gocontext.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c.addVar("conns.open", 1)
+		atomic.AddInt64(&c.health.openConns, 1)
+		return &countedConn{Conn: conn, client: c}, nil
+	}
+}
+
+// addVar adds the delta to the named metric if metrics are enabled.
+func (c *client) addVar(key string, delta int64) {
+	if c.vars == nil {
+		return
+	}
+	c.vars.Add(key, delta)
+}
+
+// addErrVar increments the metric for the category of the provided error.
+func (c *client) addErrVar(err error) {
+	if err == nil {
+		return
+	}
+	switch err.(type) {
+	case types.DriverError:
+		c.addVar("errors.driver", 1)
+	case types.TransportError:
+		c.addVar("errors.transport", 1)
+	default:
+		if httpStatus(err) > 0 {
+			c.addVar("errors.http", 1)
+		} else {
+			c.addVar("errors.other", 1)
+		}
+	}
+}
+
+// countedConn decrements the client's open connection count when closed.
+type countedConn struct {
+	net.Conn
+	client *client
+	once   sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		c.client.addVar("conns.open", -1)
+		atomic.AddInt64(&c.client.health.openConns, -1)
+		c.client.logConnClosed(c.Conn)
+	})
+	return c.Conn.Close()
+}
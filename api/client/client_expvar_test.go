@@ -0,0 +1,68 @@
+package client
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func getClientVar(key string) int64 {
+	v := expvar.Get(expvarName).(*expvar.Map).Get(key)
+	if v == nil {
+		return 0
+	}
+	i, _ := strconv.ParseInt(v.String(), 10, 64)
+	return i
+}
+
+func TestExpvar(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientExpvar, true)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/volumes/vfs/vfs-000":
+				writeJSON(w, 200, `{"id":"vfs-000"}`)
+			case "/volumes/vfs/vfs-001":
+				writeErrorCode(w, 500, "ERR_REJECTED", "request rejected")
+			default:
+				writeError(w, 404, "resource not found")
+			}
+		})
+
+	requests := getClientVar("requests")
+	driverErrs := getClientVar("errors.driver")
+	httpErrs := getClientVar("errors.http")
+	transportErrs := getClientVar("errors.transport")
+
+	ctx := context.Background()
+
+	_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), getClientVar("conns.open"))
+
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.Error(t, err)
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-002", false)
+	assert.Error(t, err)
+
+	server.Close()
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.Error(t, err)
+
+	assert.Equal(t, requests+4, getClientVar("requests"))
+	assert.Equal(t, int64(0), getClientVar("inFlight"))
+	assert.Equal(t, driverErrs+1, getClientVar("errors.driver"))
+	assert.Equal(t, httpErrs+1, getClientVar("errors.http"))
+	assert.Equal(t, transportErrs+1, getClientVar("errors.transport"))
+	assert.Equal(t, int64(0), getClientVar("conns.open"))
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akutz/goof"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (c *client) DriverFieldsType(driverName string, fieldsType interface{}) {
+	c.driverFieldsTypesRWL.Lock()
+	defer c.driverFieldsTypesRWL.Unlock()
+
+	driverName = strings.ToLower(driverName)
+
+	t := reflect.TypeOf(fieldsType)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		delete(c.driverFieldsTypes, driverName)
+		return
+	}
+	c.driverFieldsTypes[driverName] = t
+}
+
+// setServiceDriver records the name of the driver for the provided service.
+func (c *client) setServiceDriver(si *types.ServiceInfo) {
+	if si == nil || si.Driver == nil {
+		return
+	}
+	c.serviceDriversRWL.Lock()
+	defer c.serviceDriversRWL.Unlock()
+	c.serviceDrivers[si.Name] = strings.ToLower(si.Driver.Name)
+}
+
+// serviceDriver returns the lower-cased name of the service's driver. The
+// service is inspected if its driver is not yet known, and an empty string is
+// returned if the inspection fails. A failed inspection is cached so that
+// decoding a listing does not inspect the service once per volume; the
+// driver is recorded again by the next successful inspection or listing of
+// the services.
+func (c *client) serviceDriver(ctx types.Context, service string) string {
+	c.serviceDriversRWL.RLock()
+	driverName, ok := c.serviceDrivers[service]
+	c.serviceDriversRWL.RUnlock()
+	if ok {
+		return driverName
+	}
+
+	si, err := c.ServiceInspect(ctx, service)
+	if err != nil || si.Driver == nil {
+		ctx.WithField("service", service).WithError(err).Debug(
+			"error getting service driver")
+		c.serviceDriversRWL.Lock()
+		defer c.serviceDriversRWL.Unlock()
+		if _, ok := c.serviceDrivers[service]; !ok {
+			c.serviceDrivers[service] = ""
+		}
+		return ""
+	}
+	return strings.ToLower(si.Driver.Name)
+}
+
+// decodeDriverFields decodes the volume's fields into a new instance of the
+// struct type registered for the service's driver, if any.
+func (c *client) decodeDriverFields(
+	ctx types.Context, service string, v *types.Volume) {
+
+	c.driverFieldsTypesRWL.RLock()
+	empty := len(c.driverFieldsTypes) == 0
+	c.driverFieldsTypesRWL.RUnlock()
+	if empty {
+		return
+	}
+
+	driverName := c.serviceDriver(ctx, service)
+
+	c.driverFieldsTypesRWL.RLock()
+	t, ok := c.driverFieldsTypes[driverName]
+	c.driverFieldsTypesRWL.RUnlock()
+	if !ok {
+		return
+	}
+
+	fields := reflect.New(t)
+	if err := decodeFields(v.Fields, fields.Elem()); err != nil {
+		ctx.WithFields(map[string]interface{}{
+			"service":  service,
+			"volumeID": v.ID,
+		}).WithError(err).Warn("error decoding driver fields")
+		return
+	}
+	v.DriverFields = fields.Interface()
+}
+
+// decodeFields sets the exported fields of the struct value to the parsed
+// values of the matching keys in the fields map.
+func decodeFields(fields map[string]string, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		name := sf.Name
+		if tag := strings.Split(sf.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		value, ok := fieldValue(fields, name)
+		if !ok {
+			continue
+		}
+		if err := setField(rv.Field(i), value); err != nil {
+			return goof.WithFieldsE(map[string]interface{}{
+				"field": name,
+				"value": value,
+			}, "invalid driver field", err)
+		}
+	}
+	return nil
+}
+
+func fieldValue(fields map[string]string, name string) (string, bool) {
+	if value, ok := fields[name]; ok {
+		return value, true
+	}
+	for k, value := range fields {
+		if strings.EqualFold(k, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func setField(f reflect.Value, value string) error {
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return goof.WithField(
+			"kind", f.Kind().String(), "unsupported driver field type")
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+)
+
+type vfsTestFields struct {
+	Owner     string
+	Replicas  int `json:"replicas"`
+	Encrypted bool
+	Interval  time.Duration `json:"interval,omitempty"`
+	Ignored   string        `json:"-"`
+}
+
+func TestDriverFieldsType(t *testing.T) {
+
+	inspected := 0
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/vfs":
+			inspected++
+			writeJSON(w, 200, `{"name":"vfs","driver":{"name":"VFS"}}`)
+		case "/services/ebs":
+			inspected++
+			writeJSON(w, 200, `{"name":"ebs","driver":{"name":"ebs"}}`)
+		case "/volumes/vfs":
+			writeJSON(w, 200, `{"vfs-000":{"id":"vfs-000","fields":{
+				"owner":"bob",
+				"replicas":"3",
+				"encrypted":"true",
+				"interval":"5m",
+				"Ignored":"value"}}}`)
+		case "/volumes/vfs/vfs-001":
+			writeJSON(w, 200, `{"id":"vfs-001","fields":{"replicas":"x"}}`)
+		case "/volumes/ebs/ebs-000":
+			writeJSON(w, 200, `{"id":"ebs-000","fields":{"owner":"bob"}}`)
+		}
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	vols, err := c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+	assert.Nil(t, vols["vfs-000"].DriverFields)
+	assert.Equal(t, 0, inspected)
+
+	c.DriverFieldsType("vfs", &vfsTestFields{})
+
+	vols, err = c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+	if assert.IsType(t, &vfsTestFields{}, vols["vfs-000"].DriverFields) {
+		assert.Equal(t, &vfsTestFields{
+			Owner:     "bob",
+			Replicas:  3,
+			Encrypted: true,
+			Interval:  5 * time.Minute,
+		}, vols["vfs-000"].DriverFields)
+	}
+	assert.Equal(t, "bob", vols["vfs-000"].Fields["owner"])
+
+	// invalid values fall back to the fields map
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.NoError(t, err)
+	assert.Nil(t, vol.DriverFields)
+	assert.Equal(t, "x", vol.Fields["replicas"])
+
+	// services with other drivers are not decoded
+	vol, err = c.VolumeInspect(ctx, "ebs", "ebs-000", false)
+	assert.NoError(t, err)
+	assert.Nil(t, vol.DriverFields)
+
+	// each service's driver is only inspected once
+	assert.Equal(t, 2, inspected)
+
+	c.DriverFieldsType("vfs", nil)
+	vols, err = c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+	assert.Nil(t, vols["vfs-000"].DriverFields)
+}
+
+func TestDriverFieldsTypeInspectFailure(t *testing.T) {
+
+	inspected := 0
+	available := false
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/vfs":
+			inspected++
+			if !available {
+				writeError(w, 401, "unauthorized")
+				return
+			}
+			writeJSON(w, 200, `{"name":"vfs","driver":{"name":"vfs"}}`)
+		case "/volumes/vfs":
+			writeJSON(w, 200, `{
+				"vfs-000":{"id":"vfs-000","fields":{"owner":"bob"}},
+				"vfs-001":{"id":"vfs-001","fields":{"owner":"bob"}},
+				"vfs-002":{"id":"vfs-002","fields":{"owner":"bob"}}}`)
+		}
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+	c.DriverFieldsType("vfs", &vfsTestFields{})
+
+	// a failed inspection is not repeated for each volume of a listing
+	vols, err := c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+	assert.Len(t, vols, 3)
+	assert.Nil(t, vols["vfs-000"].DriverFields)
+	_, err = c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, inspected)
+
+	// the driver is recorded by the next successful inspection
+	available = true
+	_, err = c.ServiceInspect(ctx, "vfs")
+	assert.NoError(t, err)
+	vols, err = c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+	assert.IsType(t, &vfsTestFields{}, vols["vfs-000"].DriverFields)
+	assert.Equal(t, 2, inspected)
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+)
+
+// checkFreshness returns an ErrStaleData error if the client has a maximum
+// data age and the response to a read request is older than it. The age is
+// measured from the response's data timestamp header, if present, and
+// otherwise its Date header.
+func (c *client) checkFreshness(method string, res *http.Response) error {
+
+	if c.maxDataAge <= 0 ||
+		(method != http.MethodGet && method != http.MethodHead) {
+		return nil
+	}
+
+	// an unparseable timestamp is zero and treated as if it were missing
+	var timestamp time.Time
+	if v := res.Header.Get(types.DataTimestampHeader); v != "" {
+		timestamp, _ = time.Parse(time.RFC3339, v)
+	} else if v := res.Header.Get("Date"); v != "" {
+		timestamp, _ = http.ParseTime(v)
+	}
+
+	if timestamp.IsZero() || time.Since(timestamp) > c.maxDataAge {
+		return utils.NewStaleDataError(timestamp, c.maxDataAge)
+	}
+	return nil
+}
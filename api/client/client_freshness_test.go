@@ -0,0 +1,59 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestMaxDataAge(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxDataAge, "10s")
+
+	var timestamp, date string
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			if timestamp != "" {
+				w.Header().Set(types.DataTimestampHeader, timestamp)
+			}
+			w.Header().Set("Date", date)
+			writeJSON(w, 200, `{"vfs":{"name":"vfs"}}`)
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	// the data timestamp takes precedence over the date
+	timestamp = now.Add(-time.Minute).Format(time.RFC3339)
+	date = now.Format(http.TimeFormat)
+	_, err := c.Services(ctx)
+	if assert.IsType(t, &types.ErrStaleData{}, err) {
+		assert.EqualError(t, err, "response data is stale")
+	}
+
+	timestamp = now.Add(-time.Second).Format(time.RFC3339)
+	date = now.Add(-time.Minute).Format(http.TimeFormat)
+	services, err := c.Services(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, services, "vfs")
+
+	timestamp = ""
+	_, err = c.Services(ctx)
+	assert.IsType(t, &types.ErrStaleData{}, err)
+
+	date = now.Format(http.TimeFormat)
+	_, err = c.Services(ctx)
+	assert.NoError(t, err)
+
+	timestamp = "yesterday"
+	_, err = c.Services(ctx)
+	assert.EqualError(t, err, "response has no data timestamp")
+}
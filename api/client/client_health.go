@@ -0,0 +1,148 @@
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akutz/gofig"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+// healthWindow is the default number of recent requests from which a
+// client's error rate is calculated.
+const healthWindow = 100
+
+// health records the outcomes of a client's recent requests.
+type health struct {
+	sync.RWMutex
+	inFlight    int64
+	openConns   int64
+	lastSuccess time.Time
+	lastFailure time.Time
+
+	// failed is a ring buffer of the outcomes of the recent requests.
+	failed   []bool
+	next     int
+	count    int
+	failures int
+
+	// degraded indicates whether the error rate has crossed the degraded
+	// threshold and not since fallen to the recovered threshold.
+	degraded bool
+
+	// minRequests is the number of recent requests required before the
+	// error rate is compared to the thresholds.
+	minRequests int
+
+	// degradedPercent and recoveredPercent are the error rates, as
+	// percentages, at or above which the client is degraded and at or below
+	// which a degraded client has recovered.
+	degradedPercent  int
+	recoveredPercent int
+
+	onDegraded  types.HealthCallbackFunc
+	onRecovered types.HealthCallbackFunc
+}
+
+// configure sets the size of the window of recent requests and the
+// thresholds at which the client is degraded and recovered.
+func (h *health) configure(config gofig.Config) {
+	window := config.GetInt(types.ConfigClientHealthWindow)
+	if window <= 0 {
+		window = healthWindow
+	}
+	h.failed = make([]bool, window)
+	h.minRequests = config.GetInt(types.ConfigClientHealthMinRequests)
+	h.degradedPercent = config.GetInt(types.ConfigClientHealthDegradedPercent)
+	h.recoveredPercent = config.GetInt(
+		types.ConfigClientHealthRecoveredPercent)
+}
+
+// record records the outcome of a request. Only transport and server errors
+// count as failures; any other response means the server is reachable and
+// responding. The callback for the transition, if the outcome caused the
+// client to become degraded or to recover, is returned.
+func (h *health) record(err error) types.HealthCallbackFunc {
+
+	_, isTransportErr := err.(types.TransportError)
+	failed := isTransportErr || httpStatus(err) >= 500
+
+	h.Lock()
+	defer h.Unlock()
+
+	if failed {
+		h.lastFailure = time.Now()
+	} else {
+		h.lastSuccess = time.Now()
+	}
+
+	if h.failed == nil {
+		h.failed = make([]bool, healthWindow)
+	}
+	if h.count == len(h.failed) && h.failed[h.next] {
+		h.failures--
+	}
+	if failed {
+		h.failures++
+	}
+	h.failed[h.next] = failed
+	h.next = (h.next + 1) % len(h.failed)
+	if h.count < len(h.failed) {
+		h.count++
+	}
+
+	if h.degradedPercent <= 0 || h.count < h.minRequests {
+		return nil
+	}
+	percent := h.failures * 100 / h.count
+	switch {
+	case !h.degraded && percent >= h.degradedPercent:
+		h.degraded = true
+		return h.onDegraded
+	case h.degraded && percent <= h.recoveredPercent:
+		h.degraded = false
+		return h.onRecovered
+	}
+	return nil
+}
+
+// recordHealth records the outcome of a request and invokes the degraded or
+// recovered callback if the outcome caused a transition.
+func (c *client) recordHealth(err error) {
+	if f := c.health.record(err); f != nil {
+		f(c.HealthStatus())
+	}
+}
+
+func (c *client) HealthCallbacks(
+	degraded, recovered types.HealthCallbackFunc) {
+
+	c.health.Lock()
+	defer c.health.Unlock()
+	c.health.onDegraded = degraded
+	c.health.onRecovered = recovered
+}
+
+func (c *client) HealthStatus() *types.HealthStatus {
+
+	h := &c.health
+	h.RLock()
+	defer h.RUnlock()
+
+	status := &types.HealthStatus{
+		LastSuccess:    h.lastSuccess,
+		LastFailure:    h.lastFailure,
+		RecentRequests: h.count,
+		InFlight:       atomic.LoadInt64(&h.inFlight),
+		OpenConns:      atomic.LoadInt64(&h.openConns),
+		Degraded:       h.degraded,
+	}
+
+	if h.count > 0 {
+		status.ErrorRate = float64(h.failures) / float64(h.count)
+	}
+
+	return status
+}
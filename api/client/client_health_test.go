@@ -0,0 +1,128 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestHealthStatus(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/volumes/vfs/vfs-000":
+			writeJSON(w, 200, `{"id":"vfs-000"}`)
+		case "/volumes/vfs/vfs-001":
+			writeError(w, 500, "request rejected")
+		default:
+			writeError(w, 404, "resource not found")
+		}
+	})
+	defer server.Close()
+
+	status := c.HealthStatus()
+	assert.True(t, status.LastSuccess.IsZero())
+	assert.Equal(t, 0, status.RecentRequests)
+	assert.Equal(t, float64(0), status.ErrorRate)
+
+	ctx := context.Background()
+	began := time.Now()
+
+	_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-002", false)
+	assert.Error(t, err)
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.Error(t, err)
+
+	status = c.HealthStatus()
+	assert.False(t, status.LastSuccess.Before(began))
+	assert.False(t, status.LastFailure.Before(status.LastSuccess))
+	assert.Equal(t, 3, status.RecentRequests)
+	assert.InDelta(t, 1.0/3.0, status.ErrorRate, 0.001)
+	assert.Equal(t, int64(0), status.InFlight)
+	assert.Equal(t, int64(1), status.OpenConns)
+
+	server.Close()
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.Error(t, err)
+
+	status = c.HealthStatus()
+	assert.Equal(t, 4, status.RecentRequests)
+	assert.InDelta(t, 0.5, status.ErrorRate, 0.001)
+}
+
+func TestHealthCallbacks(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHealthWindow, 10)
+	config.Set(types.ConfigClientHealthMinRequests, 4)
+	config.Set(types.ConfigClientHealthDegradedPercent, 50)
+	config.Set(types.ConfigClientHealthRecoveredPercent, 20)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/volumes/vfs/vfs-001" {
+				writeError(w, 503, "service unavailable")
+				return
+			}
+			writeJSON(w, 200, `{"id":"vfs-000"}`)
+		})
+	defer server.Close()
+
+	var degraded, recovered []*types.HealthStatus
+	c.HealthCallbacks(
+		func(status *types.HealthStatus) {
+			degraded = append(degraded, status)
+		},
+		func(status *types.HealthStatus) {
+			recovered = append(recovered, status)
+		})
+
+	ctx := context.Background()
+	send := func(n int, volumeID string) {
+		for i := 0; i < n; i++ {
+			c.VolumeInspect(ctx, "vfs", volumeID, false)
+		}
+	}
+
+	// too few requests to compare the error rate to the thresholds
+	send(3, "vfs-001")
+	assert.Len(t, degraded, 0)
+	send(1, "vfs-000")
+	assert.Len(t, degraded, 1)
+
+	// recover, and then fail 4 and then 5 of the 10 most recent requests
+	send(10, "vfs-000")
+	assert.Len(t, recovered, 1)
+	send(5, "vfs-000")
+	send(4, "vfs-001")
+	assert.Len(t, degraded, 1)
+	send(1, "vfs-001")
+	if assert.Len(t, degraded, 2) {
+		assert.True(t, degraded[1].Degraded)
+		assert.Equal(t, 0.5, degraded[1].ErrorRate)
+	}
+	assert.True(t, c.HealthStatus().Degraded)
+
+	// remaining above the degraded threshold does not fire it again, and
+	// falling below it is not a recovery
+	send(3, "vfs-001")
+	send(4, "vfs-000")
+	assert.Len(t, degraded, 2)
+	assert.Len(t, recovered, 1)
+
+	send(10, "vfs-000")
+	if assert.Len(t, recovered, 2) {
+		assert.False(t, recovered[1].Degraded)
+		assert.True(t, recovered[1].ErrorRate <= 0.2)
+	}
+	assert.Len(t, degraded, 2)
+	assert.False(t, c.HealthStatus().Degraded)
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+// hedgeResult is the result of one of the requests sent by httpDoHedged.
+type hedgeResult struct {
+	res   *http.Response
+	err   error
+	reply interface{}
+}
+
+// httpDoHedged sends a request the same way as httpDoOnce. If hedging is
+// enabled and the request is a GET whose response is decoded into a reply,
+// then a second, identical request is sent if there is no response before the
+// hedge delay elapses. The first successful response received is used and
+// the other request is cancelled. The response hook and transforms are
+// invoked once, with the reply of the response that is used.
+func (c *client) httpDoHedged(
+	ctx types.Context,
+	method, path string,
+	payload, reply interface{}) (*http.Response, error) {
+
+	replyVal := reflect.ValueOf(reply)
+	if c.hedgeDelay <= 0 || method != http.MethodGet ||
+		replyVal.Kind() != reflect.Ptr || replyVal.IsNil() {
+		return c.httpDoOnce(ctx, method, path, payload, reply)
+	}
+
+	// each request decodes into its own reply so the loser cannot modify the
+	// reply of the winner
+	results := make(chan *hedgeResult, 2)
+	var cancels []gocontext.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+
+	send := func() {
+		goCtx, cancel := gocontext.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		r := &hedgeResult{
+			reply: reflect.New(replyVal.Elem().Type()).Interface(),
+		}
+		go func() {
+			r.res, r.err = c.httpDoDecode(
+				context.New(goCtx), method, path, payload, r.reply)
+			results <- r
+		}()
+	}
+
+	send()
+
+	timer := time.NewTimer(c.hedgeDelay)
+	defer timer.Stop()
+
+	var r *hedgeResult
+	select {
+	case r = <-results:
+	case <-timer.C:
+		ctx.WithField("path", path).Debug("hedging slow http request")
+		send()
+		r = <-results
+
+		// a failed request does not win while the other may still succeed
+		if r.err != nil {
+			if other := <-results; other.err == nil {
+				r = other
+			}
+		}
+	}
+
+	if r.err != nil {
+		return r.res, r.err
+	}
+	replyVal.Elem().Set(reflect.ValueOf(r.reply).Elem())
+	if err := c.processReply(path, reply); err != nil {
+		return nil, err
+	}
+	return r.res, nil
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestHedgedRequest(t *testing.T) {
+
+	var (
+		requests  int32
+		cancelled = make(chan struct{}, 1)
+	)
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPHedgeDelay, "50ms")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet &&
+				atomic.AddInt32(&requests, 1) == 1 {
+				// the first request is slow
+				select {
+				case <-r.Context().Done():
+					cancelled <- struct{}{}
+				case <-time.After(2 * time.Second):
+				}
+				return
+			}
+			writeJSON(w, 200, `{"id":"vfs-000","name":"fast"}`)
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	began := time.Now()
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "fast", vol.Name)
+	assert.True(t, time.Since(began) < time.Second)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("slow request not cancelled")
+	}
+
+	// a fast response is not hedged
+	atomic.StoreInt32(&requests, 1)
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// requests other than reads are never hedged
+	atomic.StoreInt32(&requests, 0)
+	_, err = c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
+
+func TestHedgedRequestFailure(t *testing.T) {
+
+	var (
+		requests int32
+		hooked   int32
+	)
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPHedgeDelay, "50ms")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			switch atomic.AddInt32(&requests, 1) {
+			case 1:
+				// the first request is slow but succeeds
+				time.Sleep(100 * time.Millisecond)
+				writeJSON(w, 200, `{"id":"vfs-000","name":"slow"}`)
+			case 2:
+				writeError(w, 400, "invalid request")
+			case 3:
+				time.Sleep(60 * time.Millisecond)
+				writeJSON(w, 200, `{"id":"vfs-000","name":"slow"}`)
+			default:
+				writeJSON(w, 200, `{"id":"vfs-000","name":"fast"}`)
+			}
+		})
+	defer server.Close()
+
+	c.ResponseHook(func(path string, reply interface{}) error {
+		atomic.AddInt32(&hooked, 1)
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	})
+
+	ctx := context.Background()
+
+	// a failed hedged request does not win while the other may succeed
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "slow", vol.Name)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hooked))
+
+	// the hook is invoked once, for the response that is used, even when
+	// both of the requests succeed
+	atomic.StoreInt32(&hooked, 0)
+	vol, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "fast", vol.Name)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hooked))
+}
@@ -2,17 +2,29 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
-
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/goof"
+	gocontext "golang.org/x/net/context"
 	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/emccode/libstorage/api/context"
 	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
 )
 
 type headerKey int
@@ -21,6 +33,8 @@ const (
 	transactionHeaderKey headerKey = iota
 	instanceIDHeaderKey
 	localDevicesHeaderKey
+	ifNoneMatchHeaderKey
+	costTagsHeaderKey
 )
 
 func (k headerKey) String() string {
@@ -31,6 +45,10 @@ func (k headerKey) String() string {
 		return types.InstanceIDHeader
 	case localDevicesHeaderKey:
 		return types.LocalDevicesHeader
+	case ifNoneMatchHeaderKey:
+		return "If-None-Match"
+	case costTagsHeaderKey:
+		return types.CostTagsHeader
 	}
 	panic("invalid header key")
 }
@@ -40,17 +58,348 @@ func (c *client) httpDo(
 	method, path string,
 	payload, reply interface{}) (*http.Response, error) {
 
-	reqBody, err := encPayload(payload)
+	ctx, err := withCostTagsHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	res, err := c.httpDoRetry(ctx, method, path, payload, reply)
+	c.hooksRWL.RLock()
+	observer := c.observer
+	c.hooksRWL.RUnlock()
+	if observer != nil {
+		statusCode := httpStatus(err)
+		if res != nil {
+			statusCode = res.StatusCode
+		}
+		observer(method, path, statusCode, time.Since(start), err)
+	}
+	return res, err
+}
+
+// httpDoRetry sends the request, and sends it again for each retryable
+// failure until the client's maximum number of retries is reached.
+func (c *client) httpDoRetry(
+	ctx types.Context,
+	method, path string,
+	payload, reply interface{}) (*http.Response, error) {
+
+	// ensure all attempts of the same request share a transaction
+	ctx = context.RequireTX(ctx)
+
+	var (
+		start    = time.Now()
+		attempts []types.RetryAttempt
+	)
+
+	for attempt := 1; ; attempt++ {
+
+		attemptStart := time.Now()
+		c.addVar("requests", 1)
+		c.addVar("inFlight", 1)
+		atomic.AddInt64(&c.health.inFlight, 1)
+		attemptCtx, cancel := c.withTimeout(ctx)
+		res, err := c.httpDoHedged(attemptCtx, method, path, payload, reply)
+		streamed, _ := ctx.Value(streamedKey).(bool)
+		res, err = c.releaseTimeout(
+			ctx, attemptCtx, cancel, streamed, res, err)
+		atomic.AddInt64(&c.health.inFlight, -1)
+		c.addVar("inFlight", -1)
+		c.addErrVar(err)
+		c.recordHealth(err)
+
+		if err == nil {
+			return res, nil
+		}
+		attempts = append(attempts, types.RetryAttempt{
+			Err:      err,
+			Duration: time.Since(attemptStart),
+		})
+
+		if attempt > c.maxRetries || !c.isRetryable(ctx, method, err) {
+			return res, retryError(err, attempts, start)
+		}
+
+		// a streamed payload has been consumed and cannot be sent again
+		if _, ok := payload.(io.Reader); ok {
+			return res, retryError(err, attempts, start)
+		}
+
+		// do not wait past the context's deadline only to fail anyway
+		backoff := c.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok &&
+			time.Now().Add(backoff).After(deadline) {
+			return res, retryError(err, attempts, start)
+		}
+
+		if res != nil {
+			res.Body.Close()
+		}
+
+		ctx.WithFields(log.Fields{
+			"method":  method,
+			"path":    path,
+			"attempt": attempt,
+			"backoff": backoff,
+		}).WithError(err).Warn("retrying http request")
+
+		select {
+		case <-ctx.Done():
+			return nil, retryError(newCanceledError(ctx), attempts, start)
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// retryError records the history of a request that was sent more than once
+// on the error of the final attempt if the error supports it. The error is
+// returned with its concrete type unchanged.
+func retryError(
+	err error, attempts []types.RetryAttempt, start time.Time) error {
+
+	if len(attempts) < 2 {
+		return err
+	}
+	if herr, ok := err.(interface {
+		SetRetryHistory([]types.RetryAttempt, time.Duration)
+	}); ok {
+		herr.SetRetryHistory(attempts, time.Since(start))
+	}
+	return err
+}
+
+// withTimeout returns a copy of the context that is cancelled when the
+// client's request timeout elapses, and the function that cancels it.
+func (c *client) withTimeout(
+	ctx types.Context) (types.Context, gocontext.CancelFunc) {
+
+	if c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	goCtx, cancel := gocontext.WithTimeout(ctx, c.timeout)
+	return context.New(goCtx), cancel
+}
+
+// releaseTimeout cancels the context returned by withTimeout once the attempt
+// is complete. The body of a response the caller streams is read after the
+// attempt, so its context is instead cancelled when the body is closed. An
+// error caused by the timeout rather than the caller's context is marked as a
+// timeout.
+func (c *client) releaseTimeout(
+	ctx, attemptCtx types.Context,
+	cancel gocontext.CancelFunc,
+	streamed bool,
+	res *http.Response, err error) (*http.Response, error) {
+
+	if err != nil && ctx.Err() == nil &&
+		attemptCtx.Err() == gocontext.DeadlineExceeded {
+		err = utils.NewCanceledError(
+			types.CancelReasonTimeout, attemptCtx.Err())
+	}
+	if err == nil && res != nil && res.Body != nil {
+		if streamed {
+			res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+			return res, nil
+		}
+		drainBody(res.Body)
+	}
+	cancel()
+	return res, err
+}
+
+// cancelOnClose cancels a context when the body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel gocontext.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// httpClient returns the HTTP client used for requests to the context's
+// service.
+func (c *client) httpClient(ctx types.Context) (*http.Client, error) {
+
+	service, ok := context.ServiceName(ctx)
+	if !ok {
+		return &c.Client, nil
+	}
+
+	c.serviceClientsRWL.RLock()
+	f := c.serviceTransport
+	hc, ok := c.serviceClients[service]
+	c.serviceClientsRWL.RUnlock()
+
+	if f == nil {
+		return &c.Client, nil
+	}
+	if ok {
+		return hc, nil
+	}
+
+	transport, err := f(service)
+	if err != nil {
+		return nil, err
+	}
+
+	hc = &c.Client
+	if transport != nil {
+		var rt http.RoundTripper = transport
+		if c.wrapTransport != nil {
+			rt = c.wrapTransport(transport)
+		}
+		hc = &http.Client{
+			Transport:     rt,
+			CheckRedirect: c.Client.CheckRedirect,
+		}
+	}
+
+	c.serviceClientsRWL.Lock()
+	defer c.serviceClientsRWL.Unlock()
+	c.serviceClients[service] = hc
+	return hc, nil
+}
+
+type streamedKeyType int
+
+// streamedKey is the context key for a flag indicating the caller reads the
+// body of a successful response. The body is left open and the request's
+// timeout is not released until the caller closes it, whereas the body of
+// any other response is drained when the request completes.
+const streamedKey streamedKeyType = 0
+
+type retryableKeyType int
+
+// retryableKey is the context key for a flag indicating a request with a
+// non-idempotent method may be retried because the caller reconciles the
+// effects of a duplicate request.
+const retryableKey retryableKeyType = 0
+
+// isRetryable returns a flag indicating whether a failed request may be
+// sent again. A request that failed with one of the configured retry codes
+// is always retryable. Otherwise only idempotent requests, or all requests if
+// the client is configured to retry non-idempotent requests, are retried, and
+// then only when the server could not be reached or responded with a 5xx
+// status. A driver error is the storage platform's response to the operation
+// rather than a sign the server is unavailable, so it is retried only for a
+// configured retry code, as is a response that a volume is already attached.
+// An attempt that timed out is not retried.
+func (c *client) isRetryable(
+	ctx types.Context, method string, err error) bool {
+	if code := httpErrorCode(err); code != "" {
+		for _, rc := range c.retryCodes {
+			if strings.EqualFold(rc, code) {
+				return true
+			}
+		}
+	}
+
+	retryable, _ := ctx.Value(retryableKey).(bool)
+	if !c.retryNonIdempotent && !retryable && !isIdempotent(method) {
+		return false
+	}
+
+	switch err.(type) {
+	case types.TransportError:
+		return true
+	case types.DriverError:
+		return false
+	}
+	if isAlreadyAttached(err) {
+		return false
+	}
+	return httpStatus(err) >= http.StatusInternalServerError
+}
+
+// isIdempotent returns a flag indicating whether sending a request with the
+// given method more than once has the same effect as sending it once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// backoff returns the amount of time to wait before sending a request again
+// after the given attempt. The time doubles with each attempt, up to
+// maxRetryBackoff, and a random jitter of up to half of it is subtracted so
+// that clients retrying at the same time do not do so in lockstep.
+func (c *client) backoff(attempt int) time.Duration {
+	d := c.retryBackoff
+	for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	if half := int64(d / 2); half > 0 {
+		d -= time.Duration(rand.Int63n(half + 1))
+	}
+	return d
+}
+
+func (c *client) httpDoOnce(
+	ctx types.Context,
+	method, path string,
+	payload, reply interface{}) (*http.Response, error) {
+
+	res, err := c.httpDoDecode(ctx, method, path, payload, reply)
+	if err != nil {
+		return res, err
+	}
+	if method != http.MethodHead && reply != nil {
+		if err := c.processReply(path, reply); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// httpDoDecode sends the request and decodes the response into the reply
+// without invoking the response hook or transforms.
+func (c *client) httpDoDecode(
+	ctx types.Context,
+	method, path string,
+	payload, reply interface{}) (*http.Response, error) {
+
+	reqBody, gzipped, err := encPayload(payload, c.compressThreshold)
 	if err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("http://%s%s", c.host, path)
+	host := c.requestHost(method)
+	url := fmt.Sprintf("http://%s%s", host, path)
 	req, err := http.NewRequest(method, url, reqBody)
 	if err != nil {
 		return nil, err
 	}
 
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	if _, ok := payload.(io.Reader); ok {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	if sr, ok := payload.(*sizedReader); ok && sr.size >= 0 {
+		req.ContentLength = sr.size
+	}
+
+	if c.acceptLanguage != "" {
+		req.Header.Set("Accept-Language", c.acceptLanguage)
+	}
+
+	// setting the header disables the transport's own transparent
+	// decompression, so the response is decoded by decodeContentEncoding
+	if c.compression {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
 	ctx = context.RequireTX(ctx)
 	tx := context.MustTransaction(ctx)
 	ctx = ctx.WithValue(transactionHeaderKey, tx)
@@ -121,24 +470,83 @@ func (c *client) httpDo(
 
 	c.logRequest(req)
 
-	res, err := ctxhttp.Do(ctx, &c.Client, req)
+	hc, err := c.httpClient(ctx)
 	if err != nil {
 		return nil, err
 	}
+
+	res, err := ctxhttp.Do(c.withConnTrace(ctx), hc, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, newCanceledError(ctx)
+		}
+		// a saturated limit is reported as such and is not retried
+		var bperr *types.ErrBackpressure
+		if errors.As(err, &bperr) {
+			return nil, bperr
+		}
+		if host != c.host {
+			c.setLeaderHost("")
+		}
+		return nil, &transportError{error: err, url: url}
+	}
 	defer c.setServerName(res)
+	defer c.setRateLimitStatus(res)
+
+	if err := decodeContentEncoding(res); err != nil {
+		drainBody(res.Body)
+		return nil, err
+	}
 
 	c.logResponse(res)
 
-	if res.StatusCode > 299 {
-		httpErr, err := goof.DecodeHTTPError(res.Body)
+	// a streamed payload has been consumed and cannot be sent to the leader
+	if c.isLeaderRedirect(method, res.StatusCode) {
+		if _, ok := payload.(io.Reader); !ok {
+			ctx, err := c.followLeaderRedirect(ctx, res, url)
+			if err != nil {
+				return res, err
+			}
+			return c.httpDoDecode(ctx, method, path, payload, reply)
+		}
+	}
+
+	// a 304 is only returned for a conditional request and is not an error
+	if res.StatusCode > 299 && res.StatusCode != http.StatusNotModified {
+		defer drainBody(res.Body)
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+		httpErr, err := goof.DecodeHTTPError(bytes.NewReader(body))
 		if err != nil {
-			return res, goof.WithField("status", res.StatusCode, "http error")
+			return res, newHTTPError(goof.NewHTTPError(goof.WithFields(
+				goof.Fields{
+					"status": res.StatusCode,
+					"url":    url,
+				}, http.StatusText(res.StatusCode)), res.StatusCode), body)
+		}
+		herr := newHTTPError(withURL(httpErr, url), body)
+		if verr := newValidationError(herr); verr != nil {
+			return res, verr
 		}
-		return res, httpErr
+		if herr.Status() == http.StatusInternalServerError &&
+			httpErrorCode(herr) != "" {
+			return res, newDriverError(herr)
+		}
+		return res, herr
+	}
+
+	if err := c.checkFreshness(method, res); err != nil {
+		drainBody(res.Body)
+		return nil, err
 	}
 
 	if req.Method != http.MethodHead && reply != nil {
-		if err := decRes(res.Body, reply); err != nil {
+		body := res.Body
+		if c.decodeTimeout > 0 {
+			body = newIdleTimeoutReader(body, c.decodeTimeout)
+		}
+		err := decRes(body, reply)
+		drainBody(body)
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -146,10 +554,116 @@ func (c *client) httpDo(
 	return res, nil
 }
 
+// processReply invokes the response hook, if any, and then the response
+// transforms, in order, with the decoded reply.
+func (c *client) processReply(path string, reply interface{}) error {
+	c.hooksRWL.RLock()
+	hook := c.responseHook
+	c.hooksRWL.RUnlock()
+	if hook != nil {
+		if err := hook(path, reply); err != nil {
+			return err
+		}
+	}
+	return c.transformResponse(path, reply)
+}
+
+// transformResponse invokes the response transforms, in order, with the
+// decoded reply.
+func (c *client) transformResponse(path string, reply interface{}) error {
+	c.responseTransformsRWL.RLock()
+	transforms := c.responseTransforms
+	c.responseTransformsRWL.RUnlock()
+	for _, f := range transforms {
+		if err := f(path, reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withURL returns a copy of the error with the URL of the request that
+// caused it added to the error's fields.
+func withURL(err goof.HTTPError, url string) goof.HTTPError {
+	fields := goof.Fields{}
+	for k, v := range err.Fields() {
+		fields[k] = v
+	}
+	fields["url"] = url
+	return goof.NewHTTPError(goof.WithFields(fields, err.Error()), err.Status())
+}
+
+// urlPath returns the path formatted according to the format specifier with
+// each string argument escaped as a path segment, so that service names,
+// volume IDs, and snapshot IDs that contain characters such as slashes or
+// spaces do not alter the path.
+func urlPath(format string, args ...interface{}) string {
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			args[i] = neturl.PathEscape(s)
+		}
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// httpStatus returns the HTTP status code associated with an error returned
+// by httpDo or zero if the error is not associated with a HTTP response.
+func httpStatus(err error) int {
+	switch terr := err.(type) {
+	case goof.HTTPError:
+		return terr.Status()
+	case goof.Goof:
+		if status, ok := terr.Fields()["status"].(int); ok {
+			return status
+		}
+	}
+	return 0
+}
+
+// httpErrorCode returns the server error code associated with an error
+// returned by httpDo or an empty string if there is no such code.
+func httpErrorCode(err error) string {
+	if gerr, ok := err.(goof.Goof); ok {
+		if code, ok := gerr.Fields()["code"].(string); ok {
+			return code
+		}
+	}
+	return ""
+}
+
+// isNotFoundAsNil returns a flag indicating whether the error is a 404 that
+// the client is configured to treat as a nil result.
+func (c *client) isNotFoundAsNil(err error) bool {
+	return c.notFoundAsNil && httpStatus(err) == http.StatusNotFound
+}
+
 func (c *client) setServerName(res *http.Response) {
 	c.serverName = res.Header.Get(types.ServerNameHeader)
 }
 
+// setRateLimitStatus records the rate-limit information from the response if
+// the response includes rate-limit headers.
+func (c *client) setRateLimitStatus(res *http.Response) {
+
+	limit := res.Header.Get(types.RateLimitLimitHeader)
+	remaining := res.Header.Get(types.RateLimitRemainingHeader)
+	reset := res.Header.Get(types.RateLimitResetHeader)
+	if limit == "" && remaining == "" && reset == "" {
+		return
+	}
+
+	status := &types.RateLimitStatus{}
+	status.Limit, _ = strconv.ParseInt(limit, 10, 64)
+	status.Remaining, _ = strconv.ParseInt(remaining, 10, 64)
+	if epoch, err := strconv.ParseInt(reset, 10, 64); err == nil {
+		status.Reset = time.Unix(epoch, 0)
+	}
+
+	c.rateLimitStatusRWL.Lock()
+	defer c.rateLimitStatusRWL.Unlock()
+	c.rateLimitStatus = status
+}
+
 func (c *client) httpGet(
 	ctx types.Context,
 	path string,
@@ -182,26 +696,131 @@ func (c *client) httpDelete(
 	return c.httpDo(ctx, "DELETE", path, nil, reply)
 }
 
-func encPayload(payload interface{}) (io.Reader, error) {
+// encPayload encodes the payload as JSON. If the compression threshold is
+// greater than zero and the encoded payload exceeds it, the payload is
+// gzipped and the returned flag is true. A payload that is an io.Reader is
+// returned as-is so that it is streamed to the server rather than buffered;
+// unless the reader's length is known to the http package, the request is
+// sent with chunked transfer encoding.
+func encPayload(
+	payload interface{}, compressThreshold int) (io.Reader, bool, error) {
+
 	if payload == nil {
-		return nil, nil
+		return nil, false, nil
+	}
+
+	if r, ok := payload.(io.Reader); ok {
+		return r, false, nil
 	}
 
 	buf, err := json.Marshal(payload)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	return bytes.NewReader(buf), nil
+	if compressThreshold <= 0 || len(buf) <= compressThreshold {
+		return bytes.NewReader(buf), false, nil
+	}
+
+	gzbuf := &bytes.Buffer{}
+	w := gzip.NewWriter(gzbuf)
+	if _, err := w.Write(buf); err != nil {
+		return nil, false, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, false, err
+	}
+
+	return gzbuf, true, nil
 }
 
-func decRes(body io.Reader, reply interface{}) error {
-	buf, err := ioutil.ReadAll(body)
+// sizedReader is a streamed payload with a known length. A negative size
+// indicates the length is unknown.
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+// idleTimeoutReader closes the underlying reader, causing a pending read to
+// fail with ErrDecodeTimeout, when no data is read for the timeout period.
+type idleTimeoutReader struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+
+	sync.Mutex
+	timedOut bool
+}
+
+func newIdleTimeoutReader(
+	r io.ReadCloser, timeout time.Duration) *idleTimeoutReader {
+
+	itr := &idleTimeoutReader{ReadCloser: r, timeout: timeout}
+	itr.timer = time.AfterFunc(timeout, func() {
+		itr.Lock()
+		itr.timedOut = true
+		itr.Unlock()
+		r.Close()
+	})
+	return itr
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
 	if err != nil {
-		return err
+		r.Lock()
+		defer r.Unlock()
+		if r.timedOut {
+			return n, types.ErrDecodeTimeout
+		}
+		return n, err
+	}
+	r.timer.Reset(r.timeout)
+	return n, nil
+}
+
+func (r *idleTimeoutReader) Close() error {
+	r.timer.Stop()
+	return r.ReadCloser.Close()
+}
+
+// drainBody reads any unread bytes from a response body and closes it so the
+// underlying connection can be reused.
+func drainBody(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, body)
+	body.Close()
+}
+
+// decodeContentEncoding replaces the body of a gzip-encoded response with the
+// decoded body so that the response is logged and decoded as if it had not
+// been encoded.
+func decodeContentEncoding(res *http.Response) error {
+	if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return nil
 	}
-	if err := json.Unmarshal(buf, reply); err != nil {
+	gz, err := gzip.NewReader(res.Body)
+	if err != nil {
 		return err
 	}
+	res.Body = &gzipBody{Reader: gz, body: res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
 	return nil
 }
+
+// gzipBody is a decoded response body that closes the encoded body.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+func decRes(body io.Reader, reply interface{}) error {
+	return json.NewDecoder(body).Decode(reply)
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/akutz/goof"
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+// maxLeaderRedirects is the maximum number of leader redirects followed for
+// a single request.
+const maxLeaderRedirects = 3
+
+type leaderRedirectsKeyType int
+
+// leaderRedirectsKey is the context key for the number of leader redirects
+// followed for a request.
+const leaderRedirectsKey leaderRedirectsKeyType = 0
+
+// isMutating returns a flag indicating whether requests made with the HTTP
+// method modify state on the server.
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// isLeaderRedirect returns a flag indicating whether the response status is a
+// redirect of a mutating request to the cluster leader that the client is
+// configured to follow.
+func (c *client) isLeaderRedirect(method string, status int) bool {
+	return c.followLeader && isMutating(method) &&
+		(status == http.StatusTemporaryRedirect ||
+			status == http.StatusPermanentRedirect)
+}
+
+// checkRedirect is the http.Client's CheckRedirect function when leader
+// redirects are followed. Leader redirects are returned to the client so
+// that the leader can be remembered while all other redirects are followed
+// as usual.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if isMutating(via[0].Method) &&
+		(req.Response.StatusCode == http.StatusTemporaryRedirect ||
+			req.Response.StatusCode == http.StatusPermanentRedirect) {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// requestHost returns the host to which a request with the HTTP method is sent.
+func (c *client) requestHost(method string) string {
+	if !c.followLeader || !isMutating(method) {
+		return c.host
+	}
+	c.leaderHostRWL.RLock()
+	defer c.leaderHostRWL.RUnlock()
+	if c.leaderHost == "" {
+		return c.host
+	}
+	return c.leaderHost
+}
+
+// setLeaderHost sets the host to which mutating requests are sent and
+// returns a flag indicating whether the host changed.
+func (c *client) setLeaderHost(host string) bool {
+	c.leaderHostRWL.Lock()
+	defer c.leaderHostRWL.Unlock()
+	changed := c.leaderHost != host
+	c.leaderHost = host
+	return changed
+}
+
+// warmupHost establishes the configured number of connections to the host
+// by sending concurrent HEAD requests to it. The connections are returned to
+// the idle pool once the responses are received so that they are reused by
+// the requests that follow.
+func (c *client) warmupHost(ctx types.Context, host string) {
+
+	if c.failoverWarmup <= 0 {
+		return
+	}
+
+	hc, err := c.httpClient(ctx)
+	if err != nil {
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < c.failoverWarmup; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(
+				http.MethodHead, fmt.Sprintf("http://%s/", host), nil)
+			if err != nil {
+				return
+			}
+			res, err := ctxhttp.Do(ctx, hc, req)
+			if err != nil {
+				ctx.WithField("leader", host).WithError(err).Debug(
+					"error warming up connection to leader")
+				return
+			}
+			drainBody(res.Body)
+		}()
+	}
+	wg.Wait()
+}
+
+// followLeaderRedirect remembers the host of the leader to which the response
+// redirects and returns a copy of the context with which to send the request
+// to the leader. An error is returned if the redirect is invalid or too many
+// redirects have been followed for the request.
+func (c *client) followLeaderRedirect(
+	ctx types.Context,
+	res *http.Response,
+	url string) (types.Context, error) {
+
+	drainBody(res.Body)
+
+	redirects, _ := ctx.Value(leaderRedirectsKey).(int)
+	if redirects >= maxLeaderRedirects {
+		return nil, goof.WithFields(goof.Fields{
+			"status":    res.StatusCode,
+			"url":       url,
+			"redirects": redirects,
+		}, "too many leader redirects")
+	}
+
+	loc, err := res.Location()
+	if err != nil || loc.Host == "" {
+		return nil, goof.WithFields(goof.Fields{
+			"status": res.StatusCode,
+			"url":    url,
+		}, "invalid leader redirect")
+	}
+
+	ctx.WithField("leader", loc.Host).Info("following leader redirect")
+	if c.setLeaderHost(loc.Host) {
+		c.warmupHost(ctx, loc.Host)
+	}
+	return ctx.WithValue(leaderRedirectsKey, redirects+1), nil
+}
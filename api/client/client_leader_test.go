@@ -0,0 +1,154 @@
+package client
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestLeaderRedirect(t *testing.T) {
+
+	var (
+		leaderWrites   int
+		followerWrites int
+		followerReads  int
+	)
+
+	leader := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var request types.VolumeCreateRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			leaderWrites++
+			writeJSON(w, 200, `{"id":"vfs-000","name":"`+request.Name+`"}`)
+		}))
+	defer leader.Close()
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPFollowLeaderRedirects, true)
+
+	c, follower := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				followerReads++
+				writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+				return
+			}
+			followerWrites++
+			http.Redirect(w, r,
+				leader.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+		})
+	defer follower.Close()
+
+	ctx := context.Background()
+
+	vol, err := c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "v0", vol.Name)
+	assert.Equal(t, 1, followerWrites)
+	assert.Equal(t, 1, leaderWrites)
+	assert.Equal(t, strings.TrimPrefix(leader.URL, "http://"), c.leaderHost)
+
+	// subsequent writes are sent directly to the leader
+	vol, err = c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", vol.Name)
+	assert.Equal(t, 1, followerWrites)
+	assert.Equal(t, 2, leaderWrites)
+
+	// while reads are not
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, followerReads)
+
+	// the configured server is used again once the leader is unreachable
+	leader.Close()
+	_, err = c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v2"})
+	_, ok := err.(types.TransportError)
+	assert.True(t, ok)
+	assert.Equal(t, "", c.leaderHost)
+}
+
+func TestLeaderFailoverWarmup(t *testing.T) {
+
+	var (
+		mu            sync.Mutex
+		newConns      int
+		connsAtCreate int
+	)
+
+	leader := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				return
+			}
+			mu.Lock()
+			connsAtCreate = newConns
+			mu.Unlock()
+			writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+		}))
+	leader.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			newConns++
+			mu.Unlock()
+		}
+	}
+	leader.Start()
+	defer leader.Close()
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPFollowLeaderRedirects, true)
+	config.Set(types.ConfigClientHTTPFailoverWarmup, 2)
+
+	c, follower := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r,
+				leader.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+		})
+	defer follower.Close()
+
+	_, err := c.VolumeCreate(context.Background(),
+		"vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.NoError(t, err)
+
+	// the warmed up connections are established before the redirected
+	// request, which reuses one of them
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, connsAtCreate)
+	assert.Equal(t, 2, newConns)
+}
+
+func TestLeaderRedirectLoop(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPFollowLeaderRedirects, true)
+
+	var c *client
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://"+c.host+r.URL.RequestURI(),
+				http.StatusPermanentRedirect)
+		})
+	defer server.Close()
+
+	_, err := c.VolumeCreate(context.Background(),
+		"vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.EqualError(t, err, "too many leader redirects")
+}
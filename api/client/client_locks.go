@@ -0,0 +1,126 @@
+package client
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+// instanceLockKey identifies the lock of an instance's attachments for a
+// service, or for all of the services when the service is empty.
+type instanceLockKey struct {
+	service    string
+	instanceID string
+}
+
+// instanceLock is the lock of an instance's attachments and the number of
+// operations holding or waiting for it.
+type instanceLock struct {
+	sync.RWMutex
+	refs int
+}
+
+// lockInstance blocks until no other attach or detach operation is in
+// progress for the service and instance in the context, and returns the func
+// that releases the lock. An empty service locks, for all of the services,
+// the context's instance and every instance in the context's map of all
+// instance IDs. Operations for contexts without an instance ID are not
+// serialized.
+func (c *client) lockInstance(ctx types.Context, service string) func() {
+
+	if service == "" {
+		return c.lockAllInstances(ctx)
+	}
+
+	iid, ok := context.InstanceID(ctx)
+	if !ok {
+		return func() {}
+	}
+
+	// every operation holds the lock for all of the services, shared unless
+	// it modifies the attachments of all of the services
+	unlockAll := c.acquireInstanceLock(
+		instanceLockKey{instanceID: iid.ID}, false)
+	unlock := c.acquireInstanceLock(
+		instanceLockKey{service: service, instanceID: iid.ID}, true)
+	return func() {
+		unlock()
+		unlockAll()
+	}
+}
+
+// lockAllInstances acquires, exclusively and in order, the locks for all of
+// the services of the context's instances and returns the func that releases
+// them.
+func (c *client) lockAllInstances(ctx types.Context) func() {
+
+	ids := map[string]bool{}
+	if iid, ok := context.InstanceID(ctx); ok {
+		ids[iid.ID] = true
+	}
+	if iidm, ok := ctx.Value(
+		context.AllInstanceIDsKey).(types.InstanceIDMap); ok {
+		for _, iid := range iidm {
+			if iid != nil {
+				ids[iid.ID] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	unlocks := make([]func(), len(sorted))
+	for i, id := range sorted {
+		unlocks[i] = c.acquireInstanceLock(
+			instanceLockKey{instanceID: id}, true)
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// acquireInstanceLock blocks until the lock is acquired and returns the func
+// that releases it. The lock is removed once no operation holds or waits for
+// it.
+func (c *client) acquireInstanceLock(
+	key instanceLockKey, exclusive bool) func() {
+
+	c.instanceLocksRWL.Lock()
+	if c.instanceLocks == nil {
+		c.instanceLocks = map[instanceLockKey]*instanceLock{}
+	}
+	l, ok := c.instanceLocks[key]
+	if !ok {
+		l = &instanceLock{}
+		c.instanceLocks[key] = l
+	}
+	l.refs++
+	c.instanceLocksRWL.Unlock()
+
+	if exclusive {
+		l.Lock()
+	} else {
+		l.RLock()
+	}
+
+	return func() {
+		if exclusive {
+			l.Unlock()
+		} else {
+			l.RUnlock()
+		}
+		c.instanceLocksRWL.Lock()
+		defer c.instanceLocksRWL.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(c.instanceLocks, key)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestVolumeAttachSerializedPerInstance(t *testing.T) {
+
+	var (
+		inProgressRWL sync.Mutex
+		inProgress    = map[string]int{}
+		maxInProgress = map[string]int{}
+		attaches      int32
+		arrived       sync.WaitGroup
+		barrier       bool
+	)
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, 200, `{"id":"vfs-000"}`)
+			return
+		}
+		iid := r.Header.Get(types.InstanceIDHeader)
+		inProgressRWL.Lock()
+		inProgress[iid]++
+		if inProgress[iid] > maxInProgress[iid] {
+			maxInProgress[iid] = inProgress[iid]
+		}
+		inProgressRWL.Unlock()
+
+		atomic.AddInt32(&attaches, 1)
+		if barrier {
+			// wait for the attaches to the other instances to arrive
+			arrived.Done()
+			done := make(chan struct{})
+			go func() {
+				arrived.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Error("attaches to different instances were serialized")
+			}
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		inProgressRWL.Lock()
+		inProgress[iid]--
+		inProgressRWL.Unlock()
+		writeJSON(w, 200, `{"volume":{"id":"vfs-000"},"attachToken":"t0"}`)
+	})
+	defer server.Close()
+
+	attach := func(iid string, n int) {
+		ctx := context.Background().WithValue(
+			context.InstanceIDKey, &types.InstanceID{ID: iid, Driver: "vfs"})
+		wg := sync.WaitGroup{}
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := c.VolumeAttach(
+					ctx, "vfs", "vfs-000", &types.VolumeAttachRequest{})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+	}
+
+	attach("iid-000", 4)
+	assert.Equal(t, int32(4), attaches)
+	assert.Len(t, maxInProgress, 1)
+	for _, max := range maxInProgress {
+		assert.Equal(t, 1, max)
+	}
+
+	// attaches to different instances proceed in parallel
+	barrier = true
+	iids := []string{"iid-001", "iid-002", "iid-003"}
+	arrived.Add(len(iids))
+	wg := sync.WaitGroup{}
+	for _, iid := range iids {
+		wg.Add(1)
+		go func(iid string) {
+			defer wg.Done()
+			attach(iid, 1)
+		}(iid)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(7), attaches)
+}
+
+func TestVolumeDetachAllSerializedPerInstance(t *testing.T) {
+
+	var (
+		requestsRWL sync.Mutex
+		requests    []string
+	)
+	attaching := make(chan struct{})
+	release := make(chan struct{})
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, 200, `{"id":"vfs-000"}`)
+			return
+		}
+		requestsRWL.Lock()
+		requests = append(requests, r.URL.RequestURI())
+		requestsRWL.Unlock()
+		switch r.URL.RequestURI() {
+		case "/volumes/vfs/vfs-000?attach":
+			close(attaching)
+			<-release
+			writeJSON(w, 200, `{"volume":{"id":"vfs-000"},"attachToken":"t0"}`)
+		default:
+			writeJSON(w, 200, `{}`)
+		}
+	})
+	defer server.Close()
+
+	ctx := context.Background().WithValue(
+		context.InstanceIDKey, &types.InstanceID{ID: "iid-000", Driver: "vfs"})
+
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		_, _, err := c.VolumeAttach(
+			ctx, "vfs", "vfs-000", &types.VolumeAttachRequest{})
+		assert.NoError(t, err)
+	}()
+	<-attaching
+	go func() {
+		defer wg.Done()
+		_, err := c.VolumeDetachAll(ctx, &types.VolumeDetachRequest{})
+		assert.NoError(t, err)
+	}()
+	go func() {
+		defer wg.Done()
+		_, err := c.VolumeDetachAllForService(
+			ctx, "vfs", &types.VolumeDetachRequest{})
+		assert.NoError(t, err)
+	}()
+
+	// the detaches wait for the attach to the same instance to complete
+	time.Sleep(50 * time.Millisecond)
+	requestsRWL.Lock()
+	assert.Equal(t, []string{"/volumes/vfs/vfs-000?attach"}, requests)
+	requestsRWL.Unlock()
+
+	close(release)
+	wg.Wait()
+	assert.Len(t, requests, 3)
+
+	// the locks are removed once they are released
+	assert.Empty(t, c.instanceLocks)
+}
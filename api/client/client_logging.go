@@ -24,7 +24,9 @@ func (c *client) logRequest(req *http.Request) {
 	fmt.Fprint(w, "HTTP REQUEST (CLIENT)")
 	fmt.Fprintln(w, " -------------------------")
 
-	buf, err := httputil.DumpRequest(req, true)
+	// do not dump the body of a streamed request as doing so buffers it
+	buf, err := httputil.DumpRequest(req, req.ContentLength > 0 &&
+		!isStreamContentType(req.Header.Get("Content-Type")))
 	if err != nil {
 		return
 	}
@@ -47,8 +49,7 @@ func (c *client) logResponse(res *http.Response) {
 	fmt.Fprintln(w, " -------------------------")
 
 	buf, err := httputil.DumpResponse(
-		res,
-		res.Header.Get("Content-Type") != "application/octet-stream")
+		res, !isStreamContentType(res.Header.Get("Content-Type")))
 	if err != nil {
 		return
 	}
@@ -64,3 +65,13 @@ func (c *client) logResponse(res *http.Response) {
 		fmt.Fprintln(w, scanner.Text())
 	}
 }
+
+// isStreamContentType returns a flag indicating whether the content type is
+// that of a body that is streamed and should not be logged.
+func isStreamContentType(contentType string) bool {
+	switch contentType {
+	case "application/octet-stream", "application/x-tar":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+// prefixTransform is a types.VolumeNameTransform that prepends a prefix to
+// the names of volumes sent to the server and removes it from the names of
+// volumes received from the server. It is also a types.VolumeNameMatcher, so
+// volumes without the prefix are omitted from listings.
+type prefixTransform struct {
+	prefix string
+}
+
+func (t *prefixTransform) Encode(name string) string {
+	return t.prefix + name
+}
+
+func (t *prefixTransform) Decode(name string) string {
+	return strings.TrimPrefix(name, t.prefix)
+}
+
+func (t *prefixTransform) Match(name string) bool {
+	return strings.HasPrefix(name, t.prefix)
+}
+
+// foldVolumeName returns the name lowercased if the client normalizes the
+// case of volume names, otherwise the name is returned unchanged.
+func (c *client) foldVolumeName(name string) string {
+	if c.nameCaseFold {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+func (c *client) encodeVolumeName(name string) string {
+	name = c.foldVolumeName(name)
+	if c.volumeNameTransform == nil {
+		return name
+	}
+	return c.volumeNameTransform.Encode(name)
+}
+
+// listsVolume returns a flag indicating whether a volume received in a
+// listing is returned to the caller. A volume is omitted if the client's name
+// transform is a types.VolumeNameMatcher that does not match its name.
+func (c *client) listsVolume(v *types.Volume) bool {
+	m, ok := c.volumeNameTransform.(types.VolumeNameMatcher)
+	return !ok || v == nil || m.Match(v.Name)
+}
+
+func (c *client) decodeVolume(
+	ctx types.Context, service string, v *types.Volume) *types.Volume {
+	if v == nil {
+		return nil
+	}
+	if c.volumeNameTransform != nil {
+		v.Name = c.volumeNameTransform.Decode(v.Name)
+	}
+	v.Name = c.foldVolumeName(v.Name)
+	c.decodeDriverFields(ctx, service, v)
+	return v
+}
+
+func (c *client) decodeVolumeMap(
+	ctx types.Context,
+	service string, vm types.VolumeMap) types.VolumeMap {
+	for id, v := range vm {
+		if !c.listsVolume(v) {
+			delete(vm, id)
+			continue
+		}
+		c.decodeVolume(ctx, service, v)
+	}
+	return vm
+}
+
+func (c *client) decodeServiceVolumeMap(
+	ctx types.Context,
+	svm types.ServiceVolumeMap) types.ServiceVolumeMap {
+	for service, vm := range svm {
+		c.decodeVolumeMap(ctx, service, vm)
+	}
+	return svm
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestVolumeNamePrefix(t *testing.T) {
+
+	var received types.VolumeCreateRequest
+
+	config := gofig.New()
+	config.Set(types.ConfigClientVolumeNamePrefix, "tenant1-")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == "POST":
+				if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+					writeError(w, 400, err.Error())
+					return
+				}
+				writeJSON(w, 200, `{"id":"vfs-000","name":"`+received.Name+`"}`)
+			case r.URL.Path == "/volumes":
+				writeJSON(w, 200,
+					`{"vfs":{"vfs-000":{"id":"vfs-000","name":"tenant1-v0"},`+
+						`"vfs-001":{"id":"vfs-001","name":"v1"}},`+
+						`"scaleio":{"sio-000":{"id":"sio-000","name":"v1"}}}`)
+			default:
+				writeJSON(w, 200,
+					`{"vfs-000":{"id":"vfs-000","name":"tenant1-v0"},`+
+						`"vfs-001":{"id":"vfs-001","name":"v1"}}`)
+			}
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	vol, err := c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant1-v0", received.Name)
+	assert.Equal(t, "v0", vol.Name)
+
+	// volumes without the prefix belong to other tenants and are omitted
+	vols, err := c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+	if assert.Len(t, vols, 1) {
+		assert.Equal(t, "v0", vols["vfs-000"].Name)
+	}
+
+	svm, err := c.Volumes(ctx, false)
+	assert.NoError(t, err)
+	if assert.Len(t, svm["vfs"], 1) {
+		assert.Equal(t, "v0", svm["vfs"]["vfs-000"].Name)
+	}
+	assert.Empty(t, svm["scaleio"])
+
+	var streamed []string
+	stream, errs := c.VolumesStream(ctx, false)
+	for v := range stream {
+		streamed = append(streamed, v.Name)
+	}
+	assert.NoError(t, <-errs)
+	assert.Equal(t, []string{"v0"}, streamed)
+
+	service, volumeID, err := c.ResolveVolume(ctx, "v0")
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs", service)
+	assert.Equal(t, "vfs-000", volumeID)
+
+	_, _, err = c.ResolveVolume(ctx, "v1")
+	assert.IsType(t, &types.ErrNotFound{}, err)
+
+	c.VolumeNameTransform(nil)
+	vols, err = c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant1-v0", vols["vfs-000"].Name)
+	assert.Equal(t, "v1", vols["vfs-001"].Name)
+}
+
+func TestNameCaseFold(t *testing.T) {
+
+	var received []string
+
+	config := gofig.New()
+	config.Set(types.ConfigClientNameCaseFold, true)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == "POST":
+				var request struct {
+					Name       string `json:"name"`
+					VolumeName string `json:"volumeName"`
+				}
+				json.NewDecoder(r.Body).Decode(&request)
+				name := request.Name + request.VolumeName
+				received = append(received, name)
+				writeJSON(w, 200, `{"id":"vfs-001","name":"`+name+`"}`)
+			case r.URL.Path == "/volumes/vfs/vfs-000":
+				writeJSON(w, 200, `{"id":"vfs-000","name":"Data"}`)
+			case r.URL.Path == "/volumes":
+				writeJSON(w, 200,
+					`{"vfs":{"vfs-000":{"id":"vfs-000","name":"Data"}}}`)
+			default:
+				writeJSON(w, 200,
+					`{"vfs-000":{"id":"vfs-000","name":"Data"}}`)
+			}
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	vol, err := c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "MyVol"})
+	assert.NoError(t, err)
+	assert.Equal(t, "myvol", vol.Name)
+
+	_, err = c.VolumeCopy(ctx, "vfs", "vfs-000",
+		&types.VolumeCopyRequest{VolumeName: "MyCopy"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"myvol", "mycopy"}, received)
+
+	vol, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", vol.Name)
+
+	vols, err := c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", vols["vfs-000"].Name)
+
+	for _, name := range []string{"data", "Data", "DATA"} {
+		service, volumeID, err := c.ResolveVolume(ctx, name)
+		assert.NoError(t, err)
+		assert.Equal(t, "vfs", service)
+		assert.Equal(t, "vfs-000", volumeID)
+	}
+
+	received = nil
+	result, err := c.Reconcile(ctx, "vfs", []*types.VolumeCreateRequest{
+		{Name: "DATA"},
+		{Name: "Other"},
+	}, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, result.Unchanged, 1) {
+		assert.Equal(t, "vfs-000", result.Unchanged[0].ID)
+	}
+	assert.Equal(t, []string{"other"}, received)
+}
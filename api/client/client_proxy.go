@@ -0,0 +1,182 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/akutz/goof"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+// hopHeaders are the headers that apply to a single connection and are not
+// forwarded by a proxy.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// serviceRoots are the first segments of the paths whose second segment is
+// the name of a service.
+var serviceRoots = map[string]bool{
+	"services":  true,
+	"snapshots": true,
+	"volumes":   true,
+}
+
+func (c *client) ProxyHandler() http.Handler {
+	return &proxyHandler{c}
+}
+
+// proxyHandler sends the requests it receives to the server via the client.
+type proxyHandler struct {
+	c *client
+}
+
+func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	ctx := context.New(req.Context())
+	if service, ok := proxyService(req.URL.Path); ok {
+		ctx = ctx.WithValue(context.ServiceKey, service)
+	}
+
+	// only the libStorage headers are forwarded; the downstream caller's
+	// credentials are replaced by the client's own
+	if v := req.Header.Get(types.TransactionHeader); v != "" {
+		tx := &types.Transaction{}
+		if err := tx.UnmarshalText([]byte(v)); err != nil {
+			writeProxyError(w, goof.NewHTTPError(err, http.StatusBadRequest))
+			return
+		}
+		ctx = ctx.WithValue(context.TransactionKey, tx)
+	}
+	if v, ok := req.Header[types.InstanceIDHeader]; ok {
+		ctx = ctx.WithValue(instanceIDHeaderKey, v)
+	}
+	if v, ok := req.Header[types.LocalDevicesHeader]; ok {
+		ctx = ctx.WithValue(localDevicesHeaderKey, v)
+	}
+	if v := req.Header.Get("If-None-Match"); v != "" {
+		ctx = ctx.WithValue(ifNoneMatchHeaderKey, v)
+	}
+
+	payload, err := proxyPayload(req)
+	if err != nil {
+		writeProxyError(w, goof.NewHTTPError(err, http.StatusBadRequest))
+		return
+	}
+
+	// an error response is answered with the server's headers as well, ex.
+	// so a caller can honor the server's Retry-After
+	res, err := h.c.httpDo(ctx.WithValue(streamedKey, true),
+		req.Method, req.URL.RequestURI(), payload, nil)
+	if err != nil {
+		if res != nil {
+			copyProxyHeader(w.Header(), res.Header)
+			w.Header().Del("Content-Length")
+		}
+		writeProxyError(w, err)
+		return
+	}
+	defer res.Body.Close()
+
+	copyProxyHeader(w.Header(), res.Header)
+	w.WriteHeader(res.StatusCode)
+	io.Copy(w, res.Body)
+}
+
+// proxyService returns the name of the service to which a request for the
+// given path is sent.
+func proxyService(path string) (string, bool) {
+	parts := strings.SplitN(strings.Trim(path, "/"), "/", 3)
+	if len(parts) < 2 || !serviceRoots[parts[0]] || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// proxyPayload returns the payload with which a proxied request is sent. A
+// JSON body is buffered so the request may be retried and logged, while any
+// other body is streamed to the server.
+func proxyPayload(req *http.Request) (interface{}, error) {
+	if req.Body == nil || req.ContentLength == 0 {
+		return nil, nil
+	}
+	if isStreamContentType(req.Header.Get("Content-Type")) {
+		return &sizedReader{Reader: req.Body, size: req.ContentLength}, nil
+	}
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	if !json.Valid(buf) {
+		return nil, goof.New("invalid json request body")
+	}
+	return json.RawMessage(buf), nil
+}
+
+// copyProxyHeader copies the headers of the server's response, other than
+// the hop-by-hop and sensitive headers, to the proxied response.
+func copyProxyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = v
+	}
+	for _, k := range hopHeaders {
+		dst.Del(k)
+	}
+	for _, k := range redactedHeaders {
+		dst.Del(k)
+	}
+}
+
+// writeProxyError answers a failed proxied request. An error response from
+// the server is answered with the same status and body, while a failure to
+// reach the server is a 502 and a timeout a 504.
+func writeProxyError(w http.ResponseWriter, err error) {
+
+	var (
+		httpErr goof.HTTPError
+		body    *types.HTTPError
+	)
+	switch terr := err.(type) {
+	case *types.HTTPError:
+		body = terr
+	case *types.ValidationError:
+		body = terr.HTTPError
+	case *driverError:
+		body = terr.HTTPError
+	case *types.ErrCanceled:
+		if terr.Reason == types.CancelReasonTimeout {
+			httpErr = goof.NewHTTPError(err, http.StatusGatewayTimeout)
+		}
+	case goof.HTTPError:
+		httpErr = terr
+	}
+	if body != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(body.StatusCode)
+		w.Write(body.Body)
+		return
+	}
+	if httpErr == nil {
+		httpErr = goof.NewHTTPError(err, http.StatusBadGateway)
+	}
+
+	buf, _ := json.Marshal(httpErr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpErr.Status())
+	w.Write(buf)
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestProxyHandler(t *testing.T) {
+
+	var received *http.Request
+	var body string
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		buf, _ := ioutil.ReadAll(r.Body)
+		body = string(buf)
+		w.Header().Set("Set-Cookie", "session=secret")
+		switch r.URL.Path {
+		case "/volumes/vfs/vfs-999":
+			writeError(w, 404, "resource not found")
+			return
+		case "/volumes/vfs/vfs-998":
+			w.Header().Set("Retry-After", "7")
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set(types.ServerNameHeader, "server-000")
+			writeError(w, 429, "too many requests")
+			return
+		}
+		writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+	})
+	defer server.Close()
+
+	// the client's credentials are applied by its round tripper
+	transport := &http.Transport{}
+	c.RoundTripper(roundTripperFunc(
+		func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("Authorization", "Bearer client-token")
+			return transport.RoundTrip(req)
+		}))
+
+	proxy := httptest.NewServer(c.ProxyHandler())
+	defer proxy.Close()
+
+	req, _ := http.NewRequest(
+		"GET", proxy.URL+"/volumes/vfs/vfs-000?attachments=true", nil)
+	req.Header.Set("Authorization", "Bearer downstream-token")
+	req.Header.Set("X-Downstream", "1")
+	req.Header.Set(types.InstanceIDHeader, "vfs=iid-000")
+	res, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	buf, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, `{"id":"vfs-000","name":"v0"}`, string(buf))
+	assert.Empty(t, res.Header.Get("Set-Cookie"))
+	assert.Empty(t, res.Header.Get("Authorization"))
+
+	assert.Equal(t, "/volumes/vfs/vfs-000", received.URL.Path)
+	assert.Equal(t, "attachments=true", received.URL.RawQuery)
+	assert.Equal(t, "Bearer client-token", received.Header.Get("Authorization"))
+	assert.Empty(t, received.Header.Get("X-Downstream"))
+	assert.Equal(t, "vfs=iid-000", received.Header.Get(types.InstanceIDHeader))
+	assert.NotEmpty(t, received.Header.Get(types.TransactionHeader))
+
+	res, err = http.Post(proxy.URL+"/volumes/vfs",
+		"application/json", strings.NewReader(`{"name":"v0"}`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "POST", received.Method)
+	assert.Equal(t, `{"name":"v0"}`, body)
+	assert.Equal(t, "Bearer client-token", received.Header.Get("Authorization"))
+
+	res, err = http.Get(proxy.URL + "/volumes/vfs/vfs-999")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	buf, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, 404, res.StatusCode)
+	assert.Contains(t, string(buf), "resource not found")
+
+	// the server's headers are also forwarded with an error response
+	res, err = http.Get(proxy.URL + "/volumes/vfs/vfs-998")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	buf, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, 429, res.StatusCode)
+	assert.Contains(t, string(buf), "too many requests")
+	assert.Equal(t, "7", res.Header.Get("Retry-After"))
+	assert.Equal(t, `"v1"`, res.Header.Get("ETag"))
+	assert.Equal(t, "server-000", res.Header.Get(types.ServerNameHeader))
+	assert.Empty(t, res.Header.Get("Set-Cookie"))
+
+	// the caller's transaction is forwarded rather than a new one created
+	tx, _ := types.NewTransaction()
+	req, _ = http.NewRequest("GET", proxy.URL+"/volumes/vfs/vfs-000", nil)
+	req.Header.Set(types.TransactionHeader, tx.String())
+	res, err = http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t,
+		tx.String(), received.Header.Get(types.TransactionHeader))
+
+	req.Header.Set(types.TransactionHeader, "invalid")
+	res, err = http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	res.Body.Close()
+	assert.Equal(t, 400, res.StatusCode)
+
+	res, err = http.Post(proxy.URL+"/volumes/vfs",
+		"application/json", strings.NewReader(`{"name":`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	res.Body.Close()
+	assert.Equal(t, 400, res.StatusCode)
+
+	server.Close()
+	res, err = http.Get(proxy.URL + "/volumes")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	res.Body.Close()
+	assert.Equal(t, 502, res.StatusCode)
+}
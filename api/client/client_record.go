@@ -0,0 +1,235 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/akutz/goof"
+)
+
+// redactedHeaders are the headers whose values are never written to a
+// recording.
+var redactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+	"X-Auth-Token",
+}
+
+const redactedValue = "REDACTED"
+
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URI    string      `json:"uri"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+type recording struct {
+	Request  *recordedRequest  `json:"request"`
+	Response *recordedResponse `json:"response"`
+}
+
+func (r *recordedRequest) key() string {
+	return fmt.Sprintf("%s %s", r.Method, r.URI)
+}
+
+func redactHeader(hdr http.Header) http.Header {
+	redacted := http.Header{}
+	for k, v := range hdr {
+		redacted[k] = v
+	}
+	for _, k := range redactedHeaders {
+		if _, ok := redacted[k]; ok {
+			redacted.Set(k, redactedValue)
+		}
+	}
+	return redacted
+}
+
+// recordTransport is a http.RoundTripper that writes each request/response
+// pair to a file as a line of JSON.
+type recordTransport struct {
+	transport http.RoundTripper
+	file      *recordFile
+}
+
+// recordFile is the file to which recordings are written. It is shared by the
+// record transports that wrap the transports of different services.
+type recordFile struct {
+	sync.Mutex
+	path string
+}
+
+func newRecordTransport(
+	transport http.RoundTripper, path string) *recordTransport {
+
+	return (&recordTransport{file: &recordFile{path: path}}).
+		withTransport(transport)
+}
+
+// withTransport returns a record transport that sends requests with the
+// provided transport and writes the recordings to the same file as t.
+func (t *recordTransport) withTransport(
+	transport http.RoundTripper) *recordTransport {
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &recordTransport{transport: transport, file: t.file}
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	rec := &recording{
+		Request: &recordedRequest{
+			Method: req.Method,
+			URI:    req.URL.RequestURI(),
+			Header: redactHeader(req.Header),
+		},
+	}
+
+	if req.Body != nil {
+		buf, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+		rec.Request.Body = buf
+	}
+
+	res, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(buf))
+
+	rec.Response = &recordedResponse{
+		StatusCode: res.StatusCode,
+		Header:     redactHeader(res.Header),
+		Body:       buf,
+	}
+
+	if err := t.write(rec); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (t *recordTransport) write(rec *recording) error {
+
+	t.file.Lock()
+	defer t.file.Unlock()
+
+	f, err := os.OpenFile(
+		t.file.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(rec)
+}
+
+// replayTransport is a http.RoundTripper that serves responses from a file
+// written by a recordTransport. Requests are matched by their method and URI
+// and, when a request is recorded more than once, are served in the order in
+// which they were recorded.
+type replayTransport struct {
+	sync.Mutex
+	path       string
+	once       sync.Once
+	loadErr    error
+	recordings map[string][]*recordedResponse
+}
+
+func newReplayTransport(path string) *replayTransport {
+	return &replayTransport{path: path}
+}
+
+func (t *replayTransport) load() {
+
+	f, err := os.Open(t.path)
+	if err != nil {
+		t.loadErr = err
+		return
+	}
+	defer f.Close()
+
+	t.recordings = map[string][]*recordedResponse{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		rec := &recording{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			t.loadErr = err
+			return
+		}
+		if rec.Request == nil || rec.Response == nil {
+			continue
+		}
+		k := rec.Request.key()
+		t.recordings[k] = append(t.recordings[k], rec.Response)
+	}
+	t.loadErr = scanner.Err()
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	t.once.Do(t.load)
+	if t.loadErr != nil {
+		return nil, t.loadErr
+	}
+
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	k := (&recordedRequest{
+		Method: req.Method, URI: req.URL.RequestURI()}).key()
+
+	t.Lock()
+	responses := t.recordings[k]
+	if len(responses) == 0 {
+		t.Unlock()
+		return nil, goof.WithField("request", k, "no recorded response")
+	}
+	rec := responses[0]
+	t.recordings[k] = responses[1:]
+	t.Unlock()
+
+	status := rec.StatusCode
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}, nil
+}
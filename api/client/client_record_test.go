@@ -0,0 +1,124 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestRecordReplay(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "libstorage-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPRecordFile, path)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Set-Cookie", "session=secret")
+			switch r.URL.Path {
+			case "/volumes/vfs/vfs-000":
+				writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+			case "/volumes/vfs":
+				writeJSON(w, 200, `{"id":"vfs-001","name":"v1"}`)
+			default:
+				writeError(w, 404, "resource not found")
+			}
+		})
+
+	ctx := context.Background()
+
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v0", vol.Name)
+	vol, err = c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-001", vol.ID)
+	_, err = c.SnapshotInspect(ctx, "vfs", "snap-000")
+	assert.Error(t, err)
+	server.Close()
+
+	buf, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(buf), "secret")
+	assert.Contains(t, string(buf), redactedValue)
+
+	config = gofig.New()
+	config.Set(types.ConfigClientHTTPReplayFile, path)
+	c, ok := New(server.Listener.Addr().String(), nil, config).(*client)
+	assert.True(t, ok)
+
+	vol, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v0", vol.Name)
+	vol, err = c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-001", vol.ID)
+	_, err = c.SnapshotInspect(ctx, "vfs", "snap-000")
+	assert.Error(t, err)
+	assert.Equal(t, 404, httpStatus(err))
+
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.Error(t, err)
+}
+
+func TestRecordReplayServiceTransport(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "libstorage-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+
+	serviceTransport := func(service string) (*http.Transport, error) {
+		return &http.Transport{}, nil
+	}
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPRecordFile, path)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+		})
+	c.ServiceTransport(serviceTransport)
+
+	ctx := context.Background()
+
+	// the requests sent with the transport of a service are recorded
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v0", vol.Name)
+	server.Close()
+
+	buf, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf), "/volumes/vfs/vfs-000")
+
+	// and are replayed instead of being sent to the server
+	config = gofig.New()
+	config.Set(types.ConfigClientHTTPReplayFile, path)
+	c, ok := New(server.Listener.Addr().String(), nil, config).(*client)
+	assert.True(t, ok)
+	c.ServiceTransport(serviceTransport)
+
+	vol, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v0", vol.Name)
+}
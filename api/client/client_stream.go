@@ -0,0 +1,198 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/akutz/goof"
+
+	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+)
+
+func (c *client) VolumesStream(
+	ctx types.Context,
+	attachments bool) (<-chan *types.VolumeWithService, <-chan error) {
+
+	vols := make(chan *types.VolumeWithService)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(vols)
+		if err := c.streamVolumes(ctx, attachments, vols); err != nil {
+			errs <- err
+		}
+	}()
+	return vols, errs
+}
+
+// streamVolumes decodes the volumes of all services from the response body
+// one at a time, sending each on the channel as soon as it is decoded. The
+// response hook and transforms are invoked with each volume as a listing of
+// only that volume, and a volume they remove from the listing is not sent.
+func (c *client) streamVolumes(
+	ctx types.Context,
+	attachments bool,
+	vols chan<- *types.VolumeWithService) error {
+
+	var replyErr error
+	url := urlPath("/volumes?attachments=%v", attachments)
+	err := c.decodeVolumes(ctx, url, nil,
+		func(service, volumeID string, v *types.Volume) bool {
+			reply := types.ServiceVolumeMap{service: {volumeID: v}}
+			if replyErr = c.processReply(url, &reply); replyErr != nil {
+				return false
+			}
+			v, ok := reply[service][volumeID]
+			if !ok || !c.listsVolume(v) {
+				return true
+			}
+			select {
+			case vols <- &types.VolumeWithService{
+				Volume:  c.decodeVolume(ctx, service, v),
+				Service: service,
+			}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	if replyErr != nil {
+		return replyErr
+	}
+	return err
+}
+
+// decodeVolumes sends the request for a listing of the volumes of all
+// services and decodes the volumes from the response body one at a time,
+// invoking the function with each as soon as it is decoded. An
+// ErrResultTooLarge error is returned as soon as the listing exceeds the
+// client's maximum number of volumes in a result, so that the volumes of an
+// oversized listing are never all held in memory.
+func (c *client) decodeVolumes(
+	ctx types.Context,
+	url string,
+	onService func(service string),
+	f func(service, volumeID string, v *types.Volume) bool) error {
+
+	res, err := c.httpGet(ctx.WithValue(streamedKey, true), url, nil)
+	if err != nil {
+		return err
+	}
+
+	body := res.Body
+	if c.decodeTimeout > 0 {
+		body = newIdleTimeoutReader(body, c.decodeTimeout)
+	}
+
+	var (
+		count    int
+		tooLarge error
+	)
+
+	// the remainder of an oversized listing is not read just to reuse the
+	// connection since it may be arbitrarily large
+	defer func() {
+		if tooLarge != nil {
+			body.Close()
+			return
+		}
+		drainBody(body)
+	}()
+	err = decodeServiceVolumeStream(json.NewDecoder(body), onService,
+		func(service, volumeID string, v *types.Volume) bool {
+			count++
+			if c.maxVolumesInResult > 0 && count > c.maxVolumesInResult {
+				tooLarge = utils.NewResultTooLargeError(
+					count, c.maxVolumesInResult)
+				return false
+			}
+			return f(service, volumeID, v)
+		})
+	if tooLarge != nil {
+		return tooLarge
+	}
+	if ctx.Err() != nil {
+		return newCanceledError(ctx)
+	}
+	return err
+}
+
+// decodeServiceVolumeStream decodes a types.ServiceVolumeMap, invoking the
+// function with each volume as soon as it is decoded rather than buffering
+// the entire map. The onService function, if not nil, is invoked with the
+// name of each service before its volumes. Decoding stops if the function
+// returns false.
+func decodeServiceVolumeStream(
+	dec *json.Decoder,
+	onService func(service string),
+	f func(service, volumeID string, v *types.Volume) bool) error {
+
+	if ok, err := decodeObjectStart(dec); !ok || err != nil {
+		return err
+	}
+	for dec.More() {
+		service, err := decodeObjectKey(dec)
+		if err != nil {
+			return err
+		}
+		ok, err := decodeObjectStart(dec)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if onService != nil {
+			onService(service)
+		}
+		for dec.More() {
+			volumeID, err := decodeObjectKey(dec)
+			if err != nil {
+				return err
+			}
+			v := &types.Volume{}
+			if err := dec.Decode(v); err != nil {
+				return err
+			}
+			if !f(service, volumeID, v) {
+				return nil
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// decodeObjectStart decodes the start of a JSON object. A false value is
+// returned if the object is null.
+func decodeObjectStart(dec *json.Decoder) (bool, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if tok == nil {
+		return false, nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return false, goof.WithField("token", tok, "expected json object")
+	}
+	return true, nil
+}
+
+func decodeObjectKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", goof.WithField("token", tok, "expected json object key")
+	}
+	return key, nil
+}
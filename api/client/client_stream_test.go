@@ -0,0 +1,169 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/stretchr/testify/assert"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestVolumesStream(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientVolumeNamePrefix, "t0-")
+
+	var query string
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.RawQuery
+			writeJSON(w, 200, `{"vfs":{`+
+				`"vfs-000":{"id":"vfs-000","name":"t0-v0"},`+
+				`"vfs-001":{"id":"vfs-001","name":"t0-v1"}},`+
+				`"ebs":null,"empty":{},`+
+				`"s3":{"s3-000":{"id":"s3-000","name":"t0-v2"}}}`)
+		})
+	defer server.Close()
+
+	vols, errs := c.VolumesStream(context.Background(), true)
+
+	var received []string
+	for v := range vols {
+		received = append(received, v.Service+"/"+v.ID+"/"+v.Name)
+	}
+	assert.NoError(t, <-errs)
+	assert.Equal(t, "attachments=true", query)
+
+	sort.Strings(received)
+	assert.Equal(t, []string{
+		"s3/s3-000/v2",
+		"vfs/vfs-000/v0",
+		"vfs/vfs-001/v1",
+	}, received)
+}
+
+func TestVolumesStreamTransforms(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, `{"vfs":{`+
+			`"vfs-000":{"id":"vfs-000","name":"tmp-v0"},`+
+			`"vfs-001":{"id":"vfs-001","name":"v1"},`+
+			`"vfs-002":{"id":"vfs-002","name":"v2"}}}`)
+	})
+	defer server.Close()
+
+	var hooked []string
+	c.ResponseHook(func(path string, reply interface{}) error {
+		for _, vm := range *reply.(*types.ServiceVolumeMap) {
+			for id := range vm {
+				hooked = append(hooked, id)
+			}
+		}
+		return nil
+	})
+	c.ResponseTransforms(func(path string, reply interface{}) error {
+		for _, vm := range *reply.(*types.ServiceVolumeMap) {
+			for id, v := range vm {
+				if v.Name == "v2" {
+					return goof.New("policy violation")
+				}
+				if strings.HasPrefix(v.Name, "tmp-") {
+					delete(vm, id)
+				}
+			}
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+
+	// the transforms drop vfs-000 and fail the stream at vfs-002
+	var ids []string
+	vols, errs := c.VolumesStream(ctx, false)
+	for v := range vols {
+		ids = append(ids, v.ID)
+	}
+	assert.EqualError(t, <-errs, "policy violation")
+	assert.Equal(t, []string{"vfs-001"}, ids)
+	assert.Equal(t, []string{"vfs-000", "vfs-001", "vfs-002"}, hooked)
+}
+
+func TestVolumesStreamError(t *testing.T) {
+
+	body := `{"vfs":{"vfs-000":{"id":"vfs-000"},"vfs-001":`
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, body)
+	})
+	defer server.Close()
+
+	vols, errs := c.VolumesStream(context.Background(), false)
+	n := 0
+	for range vols {
+		n++
+	}
+	assert.Equal(t, 1, n)
+	assert.Error(t, <-errs)
+
+	body = `["vfs"]`
+	vols, errs = c.VolumesStream(context.Background(), false)
+	for range vols {
+		t.Fatal("unexpected volume")
+	}
+	assert.EqualError(t, <-errs, "expected json object")
+
+	c, server = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 404, "resource not found")
+	})
+	defer server.Close()
+	vols, errs = c.VolumesStream(context.Background(), false)
+	for range vols {
+		t.Fatal("unexpected volume")
+	}
+	assert.Equal(t, 404, httpStatus(<-errs))
+}
+
+func TestVolumesStreamCancel(t *testing.T) {
+
+	done := make(chan struct{})
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"vfs":{"vfs-000":{"id":"vfs-000"},`)
+		w.(http.Flusher).Flush()
+		<-done
+	})
+	defer server.Close()
+	defer close(done)
+
+	goCtx, cancel := gocontext.WithCancel(context.Background())
+	defer cancel()
+	vols, errs := c.VolumesStream(context.New(goCtx), false)
+
+	select {
+	case v := <-vols:
+		assert.Equal(t, "vfs-000", v.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for volume")
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-vols:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for stream to close")
+	}
+	err := <-errs
+	if assert.IsType(t, &types.ErrCanceled{}, err) {
+		assert.Equal(t, types.CancelReasonCaller, err.(*types.ErrCanceled).Reason)
+	}
+}
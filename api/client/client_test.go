@@ -0,0 +1,873 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/stretchr/testify/assert"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+)
+
+func newTestClient(
+	t *testing.T, handler http.HandlerFunc) (*client, *httptest.Server) {
+
+	return newTestClientWithConfig(t, nil, handler)
+}
+
+func newTestClientWithConfig(
+	t *testing.T,
+	config gofig.Config,
+	handler http.HandlerFunc) (*client, *httptest.Server) {
+
+	server := httptest.NewServer(handler)
+	host := strings.TrimPrefix(server.URL, "http://")
+	c, ok := New(host, &http.Transport{}, config).(*client)
+	if !ok {
+		t.Fatal("invalid client type")
+	}
+	return c, server
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	buf, _ := json.Marshal(goof.NewHTTPError(goof.New(msg), status))
+	writeJSON(w, status, string(buf))
+}
+
+func writeErrorCode(w http.ResponseWriter, status int, code, msg string) {
+	buf, _ := json.Marshal(
+		goof.NewHTTPError(goof.WithField("code", code, msg), status))
+	writeJSON(w, status, string(buf))
+}
+
+func TestResponseHook(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/vfs-000") {
+			writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+			return
+		}
+		writeJSON(w, 200, `{"name":"v1"}`)
+	})
+	defer server.Close()
+
+	var hookPath string
+	c.ResponseHook(func(path string, reply interface{}) error {
+		hookPath = path
+		if v, ok := reply.(*types.Volume); ok && v.ID == "" {
+			return goof.New("volume missing id")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-000", vol.ID)
+	assert.Equal(t, "/volumes/vfs/vfs-000?attachments=false", hookPath)
+
+	vol, err = c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.Error(t, err)
+	assert.Nil(t, vol)
+	assert.EqualError(t, err, "volume missing id")
+
+	c.ResponseHook(nil)
+	vol, err = c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", vol.Name)
+}
+
+func TestResponseTransforms(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, `{"vfs":{`+
+			`"vfs-000":{"id":"vfs-000","name":"tmp-v0"},`+
+			`"vfs-001":{"id":"vfs-001","name":"v1"},`+
+			`"vfs-002":{"id":"vfs-002","name":"tmp-v2"}}}`)
+	})
+	defer server.Close()
+
+	var order []string
+	dropTemp := func(path string, reply interface{}) error {
+		order = append(order, "dropTemp")
+		if svm, ok := reply.(*types.ServiceVolumeMap); ok {
+			for _, vm := range *svm {
+				for id, v := range vm {
+					if strings.HasPrefix(v.Name, "tmp-") {
+						delete(vm, id)
+					}
+				}
+			}
+		}
+		return nil
+	}
+	var transformPath string
+	record := func(path string, reply interface{}) error {
+		order = append(order, "record")
+		transformPath = path
+		return nil
+	}
+	c.ResponseTransforms(dropTemp, record)
+
+	ctx := context.Background()
+
+	vols, err := c.Volumes(ctx, false)
+	assert.NoError(t, err)
+	assert.Len(t, vols["vfs"], 1)
+	assert.Equal(t, "v1", vols["vfs"]["vfs-001"].Name)
+	assert.Equal(t, []string{"dropTemp", "record"}, order)
+	assert.Equal(t, "/volumes?attachments=false", transformPath)
+
+	c.ResponseTransforms(func(path string, reply interface{}) error {
+		return goof.New("policy violation")
+	}, record)
+	order = nil
+	_, err = c.Volumes(ctx, false)
+	assert.EqualError(t, err, "policy violation")
+	assert.Empty(t, order)
+
+	c.ResponseTransforms()
+	vols, err = c.Volumes(ctx, false)
+	assert.NoError(t, err)
+	assert.Len(t, vols["vfs"], 3)
+}
+
+func TestRoundTripper(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, 200, `{"vfs":{"vfs-000":{"id":"vfs-000"}}}`)
+		}))
+	defer server.Close()
+
+	// the round tripper sends every request to the test server regardless of
+	// the client's host
+	var hosts []string
+	serverURL, _ := url.Parse(server.URL)
+	rt := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		req.URL.Host = serverURL.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	c := New("libstorage-server", nil, nil)
+	c.RoundTripper(rt)
+
+	ctx := context.Background()
+	vols, err := c.Volumes(ctx, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-000", vols["vfs"]["vfs-000"].ID)
+	assert.Equal(t, []string{"libstorage-server"}, hosts)
+
+	c.RoundTripper(nil)
+	_, err = c.Volumes(ctx, false)
+	assert.Error(t, err)
+	assert.Len(t, hosts, 1)
+}
+
+func TestObserver(t *testing.T) {
+
+	type observation struct {
+		method, path string
+		statusCode   int
+		err          error
+	}
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/vfs-000") {
+			writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+			return
+		}
+		writeError(w, 404, "not found")
+	})
+
+	ctx := context.Background()
+
+	// calls succeed without an observer
+	_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+
+	var observed []observation
+	c.Observer(func(
+		method, path string,
+		statusCode int,
+		latency time.Duration,
+		err error) {
+		assert.True(t, latency > 0)
+		observed = append(observed, observation{method, path, statusCode, err})
+	})
+
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.Error(t, err)
+
+	// a request that fails before a response is received has no status
+	server.Close()
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.Error(t, err)
+
+	if assert.Len(t, observed, 3) {
+		assert.Equal(t, observation{
+			"GET", "/volumes/vfs/vfs-000?attachments=false", 200, nil,
+		}, observed[0])
+		assert.Equal(t, 404, observed[1].statusCode)
+		assert.Error(t, observed[1].err)
+		assert.Equal(t, 0, observed[2].statusCode)
+		_, ok := observed[2].err.(types.TransportError)
+		assert.True(t, ok)
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+
+	var acceptLanguage []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		acceptLanguage = r.Header["Accept-Language"]
+		writeJSON(w, 200, `["/services"]`)
+	}
+
+	c, server := newTestClient(t, handler)
+	_, err := c.Root(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, acceptLanguage)
+	server.Close()
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPAcceptLanguage, "de-DE")
+	c, server = newTestClientWithConfig(t, config, handler)
+	defer server.Close()
+	_, err = c.Root(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"de-DE"}, acceptLanguage)
+}
+
+func TestCompressThreshold(t *testing.T) {
+
+	var (
+		contentEncoding string
+		received        types.VolumeCreateRequest
+	)
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPCompressThreshold, 256)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			contentEncoding = r.Header.Get("Content-Encoding")
+			var body io.Reader = r.Body
+			if contentEncoding == "gzip" {
+				gzr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					writeError(w, 400, err.Error())
+					return
+				}
+				body = gzr
+			}
+			if err := json.NewDecoder(body).Decode(&received); err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			writeJSON(w, 200, `{"id":"vfs-000","name":"`+received.Name+`"}`)
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "small"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", contentEncoding)
+	assert.Equal(t, "small", received.Name)
+
+	_, err = c.VolumeCreate(ctx, "vfs", &types.VolumeCreateRequest{
+		Name: "large",
+		Opts: map[string]interface{}{"data": strings.Repeat("a", 1024)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", contentEncoding)
+	assert.Equal(t, "large", received.Name)
+	assert.Len(t, received.Opts["data"], 1024)
+}
+
+func TestCompression(t *testing.T) {
+
+	var acceptEncoding string
+
+	// writeGzip writes a gzip-encoded JSON response
+	writeGzip := func(w http.ResponseWriter, status int, body string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(status)
+		gzw := gzip.NewWriter(w)
+		gzw.Write([]byte(body))
+		gzw.Close()
+	}
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPCompression, true)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			acceptEncoding = r.Header.Get("Accept-Encoding")
+			if r.URL.Path == "/volumes/vfs/vfs-001" {
+				buf, _ := json.Marshal(
+					goof.NewHTTPError(goof.New("not found"), 404))
+				writeGzip(w, 404, string(buf))
+				return
+			}
+			writeGzip(w, 200, `{"vfs":{"vfs-000":{"id":"vfs-000"}}}`)
+		})
+	defer server.Close()
+
+	logBuf := &bytes.Buffer{}
+	logOut := log.StandardLogger().Out
+	log.StandardLogger().Out = logBuf
+	defer func() { log.StandardLogger().Out = logOut }()
+	c.LogResponses(true)
+
+	ctx := context.Background()
+
+	vols, err := c.Volumes(ctx, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-000", vols["vfs"]["vfs-000"].ID)
+	assert.Equal(t, "gzip", acceptEncoding)
+
+	// the response is logged decoded; the log writer is asynchronous, so
+	// wait for the body to be written
+	c.LogResponses(false)
+	for i := 0; i < 100 && !strings.Contains(logBuf.String(), "vfs-000"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Contains(t, logBuf.String(), "vfs-000")
+	assert.NotContains(t, logBuf.String(), "Content-Encoding")
+
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.Equal(t, 404, httpStatus(err))
+	assert.EqualError(t, err, "not found")
+}
+
+func TestRetryCodes(t *testing.T) {
+
+	var attempts int
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxRetries, 3)
+	config.Set(types.ConfigClientHTTPRetryBackoff, "1ms")
+	config.Set(types.ConfigClientHTTPRetryCodes, []string{"ERR_DRIVER_BUSY"})
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			switch {
+			case r.URL.Path == "/volumes/vfs" && attempts < 3:
+				writeErrorCode(w, 503, "ERR_DRIVER_BUSY", "driver busy")
+			case r.URL.Path == "/volumes/vfs":
+				writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+			default:
+				writeErrorCode(w, 500, "ERR_QUOTA", "quota exceeded")
+			}
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	vol, err := c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-000", vol.ID)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	_, err = c.VolumeCreate(
+		ctx, "scaleio", &types.VolumeCreateRequest{Name: "v0"})
+	assert.Error(t, err)
+	assert.Equal(t, "ERR_QUOTA", httpErrorCode(err))
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryIdempotent(t *testing.T) {
+
+	var attempts int
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxRetries, 3)
+	config.Set(types.ConfigClientHTTPRetryBackoff, "1ms")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			switch r.URL.Path {
+			case "/volumes/vfs/vfs-000":
+				if attempts < 3 {
+					writeError(w, 503, "unavailable")
+					return
+				}
+				writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+			case "/volumes/vfs/vfs-001":
+				writeError(w, 404, "not found")
+			case "/volumes/vfs/vfs-002":
+				writeErrorCode(w, 500, "ERR_QUOTA", "quota exceeded")
+			case "/volumes/vfs/vfs-003":
+				writeError(w, 500, "internal error")
+			default:
+				writeError(w, 503, "unavailable")
+			}
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-000", vol.ID)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.Equal(t, 404, httpStatus(err))
+	assert.Equal(t, 1, attempts)
+
+	// a driver error is only retried for a configured retry code
+	attempts = 0
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-002", false)
+	assert.Equal(t, "ERR_QUOTA", httpErrorCode(err))
+	assert.Implements(t, (*types.DriverError)(nil), err)
+	assert.Equal(t, 1, attempts)
+
+	// a 500 without an error code is not a driver error and is retried
+	attempts = 0
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-003", false)
+	assert.IsType(t, &types.HTTPError{}, err)
+	assert.Equal(t, 4, attempts)
+
+	attempts = 0
+	_, err = c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.Equal(t, 503, httpStatus(err))
+	assert.Equal(t, 1, attempts)
+
+	c.retryNonIdempotent = true
+	attempts = 0
+	_, err = c.VolumeCreate(
+		ctx, "vfs", &types.VolumeCreateRequest{Name: "v0"})
+	assert.Equal(t, 503, httpStatus(err))
+	assert.Equal(t, 4, attempts)
+}
+
+func TestRetryTransportError(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxRetries, 2)
+	config.Set(types.ConfigClientHTTPRetryBackoff, "1ms")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&conns, 1)
+			conn.Close()
+		}
+	}()
+	defer l.Close()
+
+	c := New(l.Addr().String(), &http.Transport{}, config)
+	_, err = c.VolumeInspect(context.Background(), "vfs", "vfs-000", false)
+	var terr types.TransportError
+	assert.True(t, errors.As(err, &terr))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&conns))
+}
+
+func TestBackpressure(t *testing.T) {
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+		}))
+	defer server.Close()
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxRetries, 3)
+	config.Set(types.ConfigClientHTTPRetryBackoff, "1ms")
+	config.Set(types.ConfigClientHTTPMaxConcurrentDials, 1)
+	config.Set(types.ConfigClientHTTPBackpressureMode, "nonblocking")
+	limiter := utils.NewDialLimiter(config)
+
+	// occupy the only dial slot
+	held, release := make(chan struct{}), make(chan struct{})
+	go limiter.Dial(gocontext.Background(), func() (net.Conn, error) {
+		close(held)
+		<-release
+		return nil, errors.New("released")
+	})
+	<-held
+	defer close(release)
+
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		DialContext: func(
+			ctx gocontext.Context, network, addr string) (net.Conn, error) {
+			return limiter.Dial(ctx, func() (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			})
+		},
+	}
+	c := New(strings.TrimPrefix(server.URL, "http://"), transport, config)
+
+	start := time.Now()
+	_, err := c.VolumeInspect(context.Background(), "vfs", "vfs-000", false)
+	assert.IsType(t, &types.ErrBackpressure{}, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
+
+func TestRetryHistory(t *testing.T) {
+
+	var attempts int
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxRetries, 3)
+	config.Set(types.ConfigClientHTTPRetryBackoff, "1ms")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			switch {
+			case r.URL.Path == "/volumes/vfs/vfs-001" && attempts > 2:
+				writeJSON(w, 200, `{"id":"vfs-001","name":"v1"}`)
+			case r.URL.Path == "/volumes/vfs/vfs-002":
+				writeError(w, 404, "not found")
+			default:
+				writeError(w, 503, "unavailable "+strconv.Itoa(attempts))
+			}
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	herr, ok := err.(*types.HTTPError)
+	if !assert.True(t, ok, "%T", err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 4, attempts)
+	assert.EqualError(t, err, "unavailable 4")
+	assert.Equal(t, 503, herr.Status())
+	if assert.Len(t, herr.Attempts(), 4) {
+		for i, a := range herr.Attempts() {
+			assert.EqualError(t, a.Err, "unavailable "+strconv.Itoa(i+1))
+			assert.True(t, a.Duration > 0)
+		}
+		assert.Equal(t, herr.Attempts()[3].Err, err)
+	}
+	assert.True(t, herr.Elapsed() >= 3*time.Millisecond)
+
+	// a request that succeeds after retries does not fail
+	attempts = 0
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-001", vol.ID)
+
+	// a request that is sent once fails with the error of that attempt
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-002", false)
+	herr, ok = err.(*types.HTTPError)
+	if assert.True(t, ok, "%T", err) {
+		assert.Equal(t, 404, herr.Status())
+		assert.Nil(t, herr.Attempts())
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+
+	c := &client{retryBackoff: 100 * time.Millisecond}
+	for attempt, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+	} {
+		d := c.backoff(attempt + 1)
+		assert.True(t, d >= max/2 && d <= max, "attempt %d: %v", attempt, d)
+	}
+	assert.True(t, c.backoff(20) <= maxRetryBackoff)
+
+	// a retry that would wait past the deadline is not attempted
+	var attempts int
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxRetries, 3)
+	config.Set(types.ConfigClientHTTPRetryBackoff, "1s")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			writeError(w, 503, "unavailable")
+		})
+	defer server.Close()
+
+	goCtx, cancel := gocontext.WithTimeout(
+		gocontext.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.VolumeInspect(context.New(goCtx), "vfs", "vfs-000", false)
+	assert.Equal(t, 503, httpStatus(err))
+	assert.Equal(t, 1, attempts)
+	assert.True(t, time.Since(start) < 200*time.Millisecond)
+}
+
+func TestDecodeErrorReusesConnection(t *testing.T) {
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/volumes/vfs/vfs-000" {
+				writeJSON(w, 200, `{"id":"vfs-000",`+strings.Repeat(" ", 8192))
+				return
+			}
+			writeJSON(w, 200, `{"id":"vfs-001","name":"v1"}`)
+		}))
+
+	conns := 0
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns++
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	c := New(server.Listener.Addr().String(), &http.Transport{}, nil)
+	ctx := context.Background()
+
+	_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.Error(t, err)
+
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", vol.Name)
+
+	assert.Equal(t, 1, conns)
+}
+
+func TestStreamingPayload(t *testing.T) {
+
+	var (
+		transferEncoding []string
+		contentLength    int64
+		received         int64
+	)
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		transferEncoding = r.TransferEncoding
+		contentLength = r.ContentLength
+		n, err := io.Copy(ioutil.Discard, r.Body)
+		if err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+		received = n
+		writeJSON(w, 200, `{}`)
+	})
+	defer server.Close()
+
+	const chunks, chunkSize = 64, 16 * 1024
+
+	pr, pw := io.Pipe()
+	go func() {
+		chunk := []byte(strings.Repeat("a", chunkSize))
+		for i := 0; i < chunks; i++ {
+			if _, err := pw.Write(chunk); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	_, err := c.httpPost(context.Background(), "/stream", pr, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"chunked"}, transferEncoding)
+	assert.Equal(t, int64(-1), contentLength)
+	assert.Equal(t, int64(chunks*chunkSize), received)
+}
+
+func TestDecodeTimeout(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPDecodeTimeout, "100ms")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			if r.URL.Path == "/volumes/vfs/vfs-001" {
+				w.Write([]byte(`{"id":`))
+				w.(http.Flusher).Flush()
+				time.Sleep(250 * time.Millisecond)
+				return
+			}
+			for _, b := range []byte(`{"id":"vfs-000","name":"v0"}`) {
+				w.Write([]byte{b})
+				w.(http.Flusher).Flush()
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	vol, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v0", vol.Name)
+
+	_, err = c.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	assert.Equal(t, types.ErrDecodeTimeout, err)
+}
+
+func TestRequestTimeout(t *testing.T) {
+
+	var attempts int32
+	release := make(chan struct{})
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPTimeout, "50ms")
+	config.Set(types.ConfigClientHTTPMaxRetries, 3)
+	config.Set(types.ConfigClientHTTPRetryBackoff, "1ms")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/volumes" {
+				atomic.AddInt32(&attempts, 1)
+				<-release
+			}
+			writeJSON(w, 200, `{}`)
+		})
+	defer server.Close()
+	defer close(release)
+
+	ctx := context.Background()
+
+	start := time.Now()
+	_, err := c.Volumes(ctx, false)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.True(t, errors.Is(err, gocontext.DeadlineExceeded))
+	if cerr, ok := err.(*types.ErrCanceled); assert.True(t, ok) {
+		assert.Equal(t, types.CancelReasonTimeout, cerr.Reason)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// the timeout applies to each request rather than the client
+	time.Sleep(100 * time.Millisecond)
+	_, err = c.VolumesByService(ctx, "vfs", false)
+	assert.NoError(t, err)
+}
+
+func TestRequestTimeoutReleasesBody(t *testing.T) {
+
+	var conns int32
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPTimeout, "1s")
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, 200, `{}`)
+		})
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	defer server.Close()
+
+	// the body of a response whose reply is discarded is drained, so the
+	// connection is reused rather than held until the timeout elapses
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, c.VolumeRemove(ctx, "vfs", "vfs-000"))
+		assert.NoError(t, c.SnapshotRemove(ctx, "vfs", "snap-000"))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+
+	// a streamed body is read after the request returns
+	var buf bytes.Buffer
+	assert.NoError(t, c.VolumeExport(ctx, "vfs", "vfs-000", &buf))
+	assert.Equal(t, `{}`, buf.String())
+}
+
+func TestRateLimitStatus(t *testing.T) {
+
+	remaining := 10
+	reset := time.Now().Add(time.Minute).Unix()
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			remaining--
+			w.Header().Set("X-RateLimit-Limit", "10")
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		}
+		writeJSON(w, 200, `["/services"]`)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+
+	assert.Nil(t, c.RateLimitStatus())
+
+	_, err := c.Root(ctx)
+	assert.NoError(t, err)
+	status := c.RateLimitStatus()
+	if assert.NotNil(t, status) {
+		assert.Equal(t, int64(10), status.Limit)
+		assert.Equal(t, int64(9), status.Remaining)
+		assert.Equal(t, reset, status.Reset.Unix())
+	}
+
+	_, err = c.Root(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), c.RateLimitStatus().Remaining)
+
+	_, err = c.Executors(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, int64(8), c.RateLimitStatus().Remaining)
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/akutz/goof"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+// tlsVersions and tlsCipherSuites are the names of the protocol versions and
+// cipher suites. The values that are not declared by every supported version
+// of crypto/tls are literals.
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	0x0304:           "TLS 1.3",
+}
+
+var tlsCipherSuites = map[uint16]string{
+	tls.TLS_RSA_WITH_RC4_128_SHA:                "TLS_RSA_WITH_RC4_128_SHA",
+	tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA:           "TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA:            "TLS_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA:            "TLS_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256:         "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384:         "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA:        "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA:          "TLS_ECDHE_RSA_WITH_RC4_128_SHA",
+	tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA:     "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	0xcca8: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	0xcca9: "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	0x1301: "TLS_AES_128_GCM_SHA256",
+	0x1302: "TLS_AES_256_GCM_SHA384",
+	0x1303: "TLS_CHACHA20_POLY1305_SHA256",
+}
+
+func (c *client) TLSInfo() (*types.TLSConnectionInfo, error) {
+
+	if c.transport == nil {
+		return nil, goof.New("tls not configured")
+	}
+
+	var (
+		conn net.Conn
+		err  error
+		addr = hostAddr(c.host)
+	)
+
+	if c.transport.DialContext != nil {
+		conn, err = c.transport.DialContext(
+			gocontext.Background(), "tcp", addr)
+	} else if c.transport.TLSClientConfig != nil {
+		conn, err = tls.Dial("tcp", addr, c.transport.TLSClientConfig)
+	} else {
+		return nil, goof.New("tls not configured")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if cc, ok := conn.(*countedConn); ok {
+		conn = cc.Conn
+	}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil, goof.New("tls not configured")
+	}
+
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, goof.WithError("tls handshake failed", err)
+	}
+
+	state := tlsConn.ConnectionState()
+
+	info := &types.TLSConnectionInfo{
+		Version:     tlsVersionName(state.Version),
+		CipherSuite: tlsCipherSuiteName(state.CipherSuite),
+		ServerName:  state.ServerName,
+	}
+
+	for _, cert := range state.PeerCertificates {
+		info.PeerCertificates = append(
+			info.PeerCertificates, &types.TLSCertificateInfo{
+				Subject:      cert.Subject.String(),
+				Issuer:       cert.Issuer.String(),
+				SerialNumber: cert.SerialNumber.String(),
+				NotBefore:    cert.NotBefore,
+				NotAfter:     cert.NotAfter,
+				DNSNames:     cert.DNSNames,
+			})
+	}
+
+	return info, nil
+}
+
+// hostAddr returns the address the transport dials for requests to the host.
+// Requests are sent to http URLs, so a host without a port is dialed on port
+// 80.
+func hostAddr(host string) string {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		return net.JoinHostPort(host, "80")
+	}
+	return host
+}
+
+func tlsVersionName(version uint16) string {
+	if name, ok := tlsVersions[version]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
+
+func tlsCipherSuiteName(id uint16) string {
+	if name, ok := tlsCipherSuites[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", id)
+}
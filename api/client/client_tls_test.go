@@ -0,0 +1,148 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+)
+
+func TestTLSInfo(t *testing.T) {
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, 200, `["/services"]`)
+		}))
+	server.TLS = &tls.Config{
+		MaxVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	addr := server.Listener.Addr().String()
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+
+	c := New(addr, &http.Transport{
+		Dial: func(string, string) (net.Conn, error) {
+			return tls.Dial("tcp", addr, tlsConfig)
+		},
+	}, nil)
+
+	info, err := c.TLSInfo()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "TLS 1.2", info.Version)
+	assert.Equal(t, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", info.CipherSuite)
+	if assert.Len(t, info.PeerCertificates, 1) {
+		assert.Equal(t,
+			server.Certificate().SerialNumber.String(),
+			info.PeerCertificates[0].SerialNumber)
+	}
+}
+
+func TestTLSNames(t *testing.T) {
+	assert.Equal(t, "TLS 1.3", tlsVersionName(0x0304))
+	assert.Equal(t, "0x0305", tlsVersionName(0x0305))
+	assert.Equal(t, "TLS_AES_128_GCM_SHA256", tlsCipherSuiteName(0x1301))
+	assert.Equal(t, "0x00FF", tlsCipherSuiteName(0x00ff))
+}
+
+func TestTLSInfoNotConfigured(t *testing.T) {
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, `["/services"]`)
+	})
+	defer server.Close()
+
+	_, err := c.TLSInfo()
+	assert.EqualError(t, err, "tls not configured")
+}
+
+func newTestClientCert(t *testing.T, commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestServiceTransport(t *testing.T) {
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var subject string
+			if len(r.TLS.PeerCertificates) > 0 {
+				subject = r.TLS.PeerCertificates[0].Subject.CommonName
+			}
+			writeJSON(w, 200, `{"name":"`+subject+`"}`)
+		}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	addr := server.Listener.Addr().String()
+	newTransport := func(certs ...tls.Certificate) *http.Transport {
+		tlsConfig := &tls.Config{
+			Certificates:       certs,
+			InsecureSkipVerify: true,
+		}
+		return &http.Transport{
+			Dial: func(string, string) (net.Conn, error) {
+				return tls.Dial("tcp", addr, tlsConfig)
+			},
+		}
+	}
+
+	certs := map[string]tls.Certificate{
+		"vfs-00": newTestClientCert(t, "vfs-00-client"),
+		"vfs-01": newTestClientCert(t, "vfs-01-client"),
+	}
+
+	c := New(addr, newTransport(), nil)
+	c.ServiceTransport(func(service string) (*http.Transport, error) {
+		if cert, ok := certs[service]; ok {
+			return newTransport(cert), nil
+		}
+		return nil, nil
+	})
+
+	ctx := context.Background()
+
+	for _, service := range []string{"vfs-00", "vfs-01", "vfs-00"} {
+		si, err := c.ServiceInspect(ctx, service)
+		assert.NoError(t, err)
+		assert.Equal(t, service+"-client", si.Name)
+	}
+
+	si, err := c.ServiceInspect(ctx, "vfs-02")
+	assert.NoError(t, err)
+	assert.Equal(t, "", si.Name)
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+// connLifecycleMsg is the message of the events logged for the stages of a
+// connection's lifecycle.
+const connLifecycleMsg = "http connection lifecycle"
+
+// withConnTrace returns a copy of the context that logs an event for each
+// stage of the lifecycle of the connection used to send a request, or the
+// context itself if connection tracing is disabled.
+func (c *client) withConnTrace(ctx types.Context) types.Context {
+
+	if !c.traceConns {
+		return ctx
+	}
+
+	logEvent := func(event string, fields log.Fields, err error) {
+		fields["event"] = event
+		entry := ctx.WithFields(fields)
+		if err != nil {
+			entry = entry.WithError(err)
+		}
+		entry.Info(connLifecycleMsg)
+	}
+
+	return context.New(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		ConnectStart: func(network, addr string) {
+			logEvent("dialStart",
+				log.Fields{"network": network, "addr": addr}, nil)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			logEvent("dialDone",
+				log.Fields{"network": network, "addr": addr}, err)
+		},
+		TLSHandshakeStart: func() {
+			logEvent("tlsHandshakeStart", log.Fields{}, nil)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			logEvent("tlsHandshakeDone", log.Fields{
+				"version": tlsVersionName(state.Version),
+				"resumed": state.DidResume,
+			}, err)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			fields := log.Fields{
+				"reused":  info.Reused,
+				"wasIdle": info.WasIdle,
+			}
+			if info.WasIdle {
+				fields["idleTime"] = info.IdleTime
+			}
+			addConnAddrs(fields, info.Conn)
+			logEvent("connAcquired", fields, nil)
+		},
+		PutIdleConn: func(err error) {
+			logEvent("connIdle", log.Fields{}, err)
+		},
+	}))
+}
+
+// logConnClosed logs the event for a closed connection if connection tracing
+// is enabled.
+func (c *client) logConnClosed(conn net.Conn) {
+	if !c.traceConns {
+		return
+	}
+	fields := log.Fields{"event": "closed"}
+	addConnAddrs(fields, conn)
+	log.WithFields(fields).Info(connLifecycleMsg)
+}
+
+func addConnAddrs(fields log.Fields, conn net.Conn) {
+	if conn == nil {
+		return
+	}
+	if addr := conn.LocalAddr(); addr != nil {
+		fields["localAddr"] = addr.String()
+	}
+	if addr := conn.RemoteAddr(); addr != nil {
+		fields["remoteAddr"] = addr.String()
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+// connEvents returns the connection lifecycle events in the log output.
+func connEvents(out string) []string {
+	var events []string
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.Contains(line, connLifecycleMsg) {
+			continue
+		}
+		i := strings.Index(line, "event=")
+		if i < 0 {
+			continue
+		}
+		event := line[i+len("event="):]
+		if j := strings.IndexByte(event, ' '); j >= 0 {
+			event = event[:j]
+		}
+		if strings.Contains(line, "reused=true") {
+			event += ":reused"
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestTraceConnections(t *testing.T) {
+
+	logBuf := &bytes.Buffer{}
+	logOut := log.StandardLogger().Out
+	log.StandardLogger().Out = logBuf
+	defer func() { log.StandardLogger().Out = logOut }()
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPTraceConnections, true)
+
+	c, server := newTestClientWithConfig(t, config,
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+		})
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+		assert.NoError(t, err)
+	}
+	server.Close()
+	c.transport.CloseIdleConnections()
+
+	assert.Equal(t, []string{
+		"dialStart",
+		"dialDone",
+		"connAcquired",
+		"connIdle",
+		"connAcquired:reused",
+		"connIdle",
+		"closed",
+	}, connEvents(logBuf.String()))
+
+	// no events are logged when tracing is disabled
+	logBuf.Reset()
+	c, server = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, `{"id":"vfs-000","name":"v0"}`)
+	})
+	defer server.Close()
+	_, err := c.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Empty(t, connEvents(logBuf.String()))
+}
@@ -259,6 +259,65 @@ func LocalDevices(ctx context.Context) (*types.LocalDevices, bool) {
 	return v, ok
 }
 
+// WithCostTags returns a new context with the tags the client sends with
+// each request so the server can attribute the request's usage.
+func WithCostTags(
+	parent context.Context, tags map[string]string) types.Context {
+	return newContext(parent, CostTagsKey, tags, nil, nil)
+}
+
+// CostTags returns the context's cost allocation tags. This value is only
+// valid for contexts created on the client.
+func CostTags(ctx context.Context) (map[string]string, bool) {
+	v, ok := ctx.Value(CostTagsKey).(map[string]string)
+	return v, ok
+}
+
+// WithPriority returns a new context with the priority with which the
+// client admits the requests made with the context when the client's
+// concurrency limit is reached.
+func WithPriority(
+	parent context.Context, priority types.RequestPriority) types.Context {
+	return newContext(parent, PriorityKey, priority, nil, nil)
+}
+
+// Priority returns the context's request priority, or PriorityNormal if the
+// context does not have one. This value is only valid for contexts created
+// on the client.
+func Priority(ctx context.Context) types.RequestPriority {
+	v, _ := ctx.Value(PriorityKey).(types.RequestPriority)
+	return v
+}
+
+// WithRoundTripper returns a new context with the round tripper used to send
+// the requests of the clients initialized with the context instead of a
+// transport that dials the configured host.
+func WithRoundTripper(
+	parent context.Context, rt http.RoundTripper) types.Context {
+	return newContext(parent, RoundTripperKey, rt, nil, nil)
+}
+
+// RoundTripper returns the context's round tripper. This value is only valid
+// for contexts created on the client.
+func RoundTripper(ctx context.Context) (http.RoundTripper, bool) {
+	v, ok := ctx.Value(RoundTripperKey).(http.RoundTripper)
+	return v, ok
+}
+
+// WithObserver returns a new context with the function invoked with the
+// outcome of every request sent by the clients initialized with the context.
+func WithObserver(
+	parent context.Context, observer types.ObserverFunc) types.Context {
+	return newContext(parent, ObserverKey, observer, nil, nil)
+}
+
+// Observer returns the context's observer. This value is only valid for
+// contexts created on the client.
+func Observer(ctx context.Context) (types.ObserverFunc, bool) {
+	v, ok := ctx.Value(ObserverKey).(types.ObserverFunc)
+	return v, ok
+}
+
 // Transaction returns the context's Transaction. This value is valid on both
 // the client and the server.
 func Transaction(ctx context.Context) (*types.Transaction, bool) {
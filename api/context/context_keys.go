@@ -33,6 +33,23 @@ const (
 	// AdminTokenKey is the key for the server's admin token.
 	AdminTokenKey
 
+	// CostTagsKey is the key for the map[string]string value of the tags
+	// the client sends with each request for server-side cost allocation.
+	CostTagsKey
+
+	// PriorityKey is the key for the types.RequestPriority value with which
+	// the client admits a request's dials ahead of those of other requests.
+	PriorityKey
+
+	// RoundTripperKey is the key for the http.RoundTripper value with which
+	// the clients initialized with the context send their requests.
+	RoundTripperKey
+
+	// ObserverKey is the key for the types.ObserverFunc value invoked with
+	// the outcome of every request sent by the clients initialized with the
+	// context.
+	ObserverKey
+
 	// keyLoggable is the minimum value from which the succeeding keys should
 	// be checked when logging.
 	keyLoggable
@@ -2,7 +2,9 @@ package types
 
 import (
 	"io"
+	"net/http"
 	"strings"
+	"time"
 )
 
 // ClientType is a client's type.
@@ -65,6 +67,144 @@ type Client interface {
 	Executor() StorageExecutorCLI
 }
 
+// ResponseHookFunc is a function invoked by the API client after a response
+// has been successfully decoded into the reply object. Returning an error from
+// the function causes the API call to fail with that error.
+type ResponseHookFunc func(path string, reply interface{}) error
+
+// ObserverFunc is a function invoked by the API client after each API call,
+// including calls that fail before a response is received, in which case the
+// status code is zero. The latency includes the time spent retrying the
+// request, if any.
+type ObserverFunc func(
+	method, path string,
+	statusCode int,
+	latency time.Duration,
+	err error)
+
+// HealthCallbackFunc is a function invoked by the API client when its recent
+// error rate crosses the degraded threshold or falls to the recovered
+// threshold. The function is invoked by the goroutine whose request caused
+// the transition and should not block.
+type HealthCallbackFunc func(status *HealthStatus)
+
+// ResponseTransformFunc is a function invoked by the API client after a
+// response has been successfully decoded into the reply object, which the
+// function may modify in place, such as by removing volumes from a volume map.
+// Returning an error from the function causes the API call to fail with that
+// error.
+type ResponseTransformFunc func(path string, reply interface{}) error
+
+// ServiceTransportFunc is a function invoked by the API client to obtain the
+// transport used for requests to a service. Returning a nil transport causes
+// the client's default transport to be used for the service.
+type ServiceTransportFunc func(service string) (*http.Transport, error)
+
+// VolumeNameTransform transforms volume names as they are sent to and
+// received from a server. The transform must be symmetric; Decode(Encode(n))
+// must equal n.
+type VolumeNameTransform interface {
+
+	// Encode transforms a volume name provided by a caller into the name
+	// sent to the server.
+	Encode(name string) string
+
+	// Decode transforms a volume name received from the server into the
+	// name returned to the caller.
+	Decode(name string) string
+}
+
+// VolumeNameMatcher may be implemented by a VolumeNameTransform that applies
+// to only some of a server's volumes, such as the volumes of one tenant.
+// Volumes whose names do not match are omitted from volume listings.
+type VolumeNameMatcher interface {
+
+	// Match returns a flag indicating whether the name received from the
+	// server is the name of a volume to which the transform applies.
+	Match(name string) bool
+}
+
+// RateLimitStatus is the rate-limit information returned by a server or a
+// gateway in front of a server.
+type RateLimitStatus struct {
+	// Limit is the maximum number of requests permitted in the current
+	// rate-limit window.
+	Limit int64 `json:"limit" yaml:"limit"`
+
+	// Remaining is the number of requests remaining in the current
+	// rate-limit window.
+	Remaining int64 `json:"remaining" yaml:"remaining"`
+
+	// Reset is the time at which the current rate-limit window resets.
+	Reset time.Time `json:"reset" yaml:"reset"`
+}
+
+// HealthStatus is a snapshot of the health of an API client's connection to
+// its server.
+type HealthStatus struct {
+	// LastSuccess is the time at which the most recent request that reached
+	// the server without a server error completed. The value is the zero
+	// time if no such request has completed.
+	LastSuccess time.Time `json:"lastSuccess" yaml:"lastSuccess"`
+
+	// LastFailure is the time at which the most recent request that failed
+	// due to a transport or server error completed.
+	LastFailure time.Time `json:"lastFailure" yaml:"lastFailure"`
+
+	// RecentRequests is the number of recent requests from which the error
+	// rate is calculated.
+	RecentRequests int `json:"recentRequests" yaml:"recentRequests"`
+
+	// ErrorRate is the fraction, from zero to one, of the recent requests
+	// that failed due to a transport or server error.
+	ErrorRate float64 `json:"errorRate" yaml:"errorRate"`
+
+	// InFlight is the number of requests in progress.
+	InFlight int64 `json:"inFlight" yaml:"inFlight"`
+
+	// OpenConns is the number of open connections to the server.
+	OpenConns int64 `json:"openConns" yaml:"openConns"`
+
+	// Degraded indicates whether the error rate has crossed the degraded
+	// threshold and not since fallen to the recovered threshold.
+	Degraded bool `json:"degraded" yaml:"degraded"`
+}
+
+// AuditRecord is a record of a mutating operation performed by the API client.
+type AuditRecord struct {
+	// Time is the time at which the operation completed.
+	Time time.Time `json:"time" yaml:"time"`
+
+	// Operation is the name of the operation, ex. VolumeCreate.
+	Operation string `json:"operation" yaml:"operation"`
+
+	// Service is the name of the service against which the operation was
+	// performed.
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+
+	// VolumeID is the ID of the volume affected by the operation.
+	VolumeID string `json:"volumeID,omitempty" yaml:"volumeID,omitempty"`
+
+	// SnapshotID is the ID of the snapshot affected by the operation.
+	SnapshotID string `json:"snapshotID,omitempty" yaml:"snapshotID,omitempty"`
+
+	// Principal is the user on whose behalf the operation was performed.
+	Principal string `json:"principal,omitempty" yaml:"principal,omitempty"`
+
+	// Outcome is either "success" or "failure".
+	Outcome string `json:"outcome" yaml:"outcome"`
+
+	// Error is the error message when the operation failed.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Auditor receives the audit records of mutating operations.
+type Auditor interface {
+
+	// Audit writes an audit record.
+	Audit(record *AuditRecord) error
+}
+
 // ProvidesAPIClient is any type that provides the API client.
 type ProvidesAPIClient interface {
 
@@ -82,15 +222,97 @@ type APIClient interface {
 	// when the server starts for the first time.
 	ServerName() string
 
+	// ServiceName returns the concrete name of the service to which the name
+	// is aliased, or the name if it is not an alias.
+	ServiceName(name string) string
+
+	// RateLimitStatus returns the rate-limit information from the most
+	// recent response that included rate-limit headers or nil if no such
+	// response has been received.
+	RateLimitStatus() *RateLimitStatus
+
+	// HealthStatus returns a snapshot of the health of the client's
+	// connection to its server.
+	HealthStatus() *HealthStatus
+
+	// HealthCallbacks sets the functions invoked once when the client's
+	// recent error rate crosses the degraded threshold, and once when it
+	// subsequently falls to the recovered threshold. A nil value removes the
+	// corresponding callback.
+	HealthCallbacks(degraded, recovered HealthCallbackFunc)
+
 	// LogRequests enables or disables the logging of client HTTP requests.
 	LogRequests(enabled bool)
 
 	// LogResponses enables or disables the logging of client HTTP responses.
 	LogResponses(enabled bool)
 
+	// ResponseHook sets the function invoked after a response is successfully
+	// decoded. A nil value removes the hook.
+	ResponseHook(hook ResponseHookFunc)
+
+	// Observer sets the function invoked after each API call, ex. to record
+	// latency and error metrics. A nil value removes the observer.
+	Observer(observer ObserverFunc)
+
+	// ResponseTransforms sets the functions invoked, in order, after a
+	// response is successfully decoded and the response hook, if any, has
+	// been invoked. Calling it without any functions removes the transforms.
+	ResponseTransforms(transforms ...ResponseTransformFunc)
+
+	// RoundTripper sets the round tripper used to send requests instead of
+	// the client's transport, ex. a stub for tests or a transport that routes
+	// requests through a proxy. Requests sent with the round tripper are still
+	// logged. A nil value causes the client's transport to be used.
+	RoundTripper(rt http.RoundTripper)
+
+	// ProxyHandler returns an http.Handler that sends the requests it
+	// receives to the server via the client, applying the client's
+	// transport, TLS configuration, retries, and logging. Only the
+	// libStorage headers of a request are forwarded, and the sensitive
+	// headers of the server's response are removed.
+	ProxyHandler() http.Handler
+
+	// ServiceTransport sets the function used to obtain the transport for
+	// requests to a service. The transport returned for a service is cached.
+	// A nil value causes the default transport to be used for all services.
+	ServiceTransport(f ServiceTransportFunc)
+
+	// VolumeNameTransform sets the transform applied to volume names sent to
+	// and received from the server. A nil value removes the transform.
+	VolumeNameTransform(transform VolumeNameTransform)
+
+	// DriverFieldsType registers the struct type into which the Fields of the
+	// volumes that belong to services with the named driver are decoded. The
+	// fieldsType may be a struct or a pointer to one, and a nil value removes
+	// the registration. Fields are matched to struct fields using the json
+	// tag, if any, or else the field name, ignoring case.
+	DriverFieldsType(driverName string, fieldsType interface{})
+
+	// Auditor sets the auditor that receives a record of each mutating
+	// operation. A nil value disables auditing.
+	Auditor(auditor Auditor)
+
+	// TLSInfo performs a TLS handshake with the server and returns the
+	// negotiated parameters. An error is returned if the client is not
+	// configured to use TLS.
+	TLSInfo() (*TLSConnectionInfo, error)
+
+	// Close releases the client's idle connections. If the client is
+	// configured to detach volumes on close, then each volume the client
+	// attached and did not detach is detached first. A failure to detach a
+	// volume is logged and does not cause Close to fail.
+	Close(ctx Context) error
+
 	// Root returns a list of root resources.
 	Root(ctx Context) ([]string, error)
 
+	// Verify confirms the server is a libStorage server by inspecting its
+	// list of root resources and the headers of the response. An
+	// ErrNotLibStorageServer error is returned if the server responds but
+	// is not a libStorage server.
+	Verify(ctx Context) error
+
 	// Instances returns a list of instances.
 	Instances(ctx Context) (map[string]*Instance, error)
 
@@ -103,18 +325,100 @@ type APIClient interface {
 	// ServiceInspect returns information about a service.
 	ServiceInspect(ctx Context, name string) (*ServiceInfo, error)
 
+	// ServiceCapacity returns the aggregate storage capacity of a service. If
+	// the service's driver cannot report its capacity then an
+	// ErrCapabilityNotImplemented error is returned.
+	ServiceCapacity(ctx Context, name string) (*CapacityInfo, error)
+
+	// WaitForService polls a service with an increasing backoff until the
+	// service is available or the context is done.
+	WaitForService(ctx Context, name string) (*ServiceInfo, error)
+
+	// SortedVolumes returns the volumes from all services as a single list
+	// sorted by the provided key: "name", "size", or "created". Volumes with
+	// equal keys are sorted by their IDs.
+	SortedVolumes(ctx Context, by string) ([]*VolumeWithService, error)
+
+	// ResolveVolume searches all services for the volume with the provided
+	// name and returns the name of the service and the volume's ID. If the
+	// name matches volumes in more than one service then an
+	// ErrAmbiguousVolume error is returned.
+	ResolveVolume(
+		ctx Context,
+		name string) (service, volumeID string, err error)
+
+	// VolumesStream returns a channel on which the volumes of all services
+	// are sent as they are decoded from the server's response, so that the
+	// entire listing is never held in memory, and a channel that receives
+	// the error, if any, that ended the stream. The volumes channel is closed
+	// when the listing is complete or the context is cancelled, after which
+	// the error channel is closed. The response hook and transforms are
+	// invoked with each volume as a ServiceVolumeMap of only that volume, and
+	// a volume they remove from the map is not sent.
+	VolumesStream(
+		ctx Context,
+		attachments bool) (<-chan *VolumeWithService, <-chan error)
+
+	// VolumeStateCounts returns the number of a service's volumes in each
+	// state. Volumes whose status is not recognized are counted as
+	// VolumeStateUnknown.
+	VolumeStateCounts(
+		ctx Context,
+		service string) (map[VolumeState]int, error)
+
+	// VolumeAttachedTo returns the IDs of the instances to which the volume
+	// is attached, or an empty list if the volume is not attached.
+	VolumeAttachedTo(
+		ctx Context,
+		service, volumeID string) ([]string, error)
+
+	// VolumesCreateChan creates a volume for each of the provided requests and
+	// returns a channel on which the result of each request is sent as soon
+	// as the request completes. The requests are sent concurrently, so the
+	// results may not arrive in the order of the requests. The channel is
+	// closed once all of the requests have completed or the context is
+	// cancelled.
+	VolumesCreateChan(
+		ctx Context,
+		service string,
+		requests []*VolumeCreateRequest) <-chan *VolumeCreateResult
+
+	// Reconcile creates the desired volumes that do not exist for the service,
+	// matching volumes by name, and if the options indicate to prune, removes
+	// the service's volumes that are not desired. Reconciling the same desired
+	// volumes again makes no further changes. If a change fails then an
+	// ErrBatchProcess error is returned that contains the changes made.
+	Reconcile(
+		ctx Context,
+		service string,
+		desired []*VolumeCreateRequest,
+		opts *ReconcileOpts) (*ReconcileResult, error)
+
 	// Volumes returns a list of all Volumes for all Services.
 	Volumes(
 		ctx Context,
 		attachments bool) (ServiceVolumeMap, error)
 
-	// VolumesByService returns a list of all Volumes for a service.
+	// VolumesChanged issues a conditional request that returns a flag
+	// indicating whether the volume listing returned by Volumes has changed
+	// since the listing with the provided ETag, as well as the listing's
+	// current ETag. The listing is only transferred, and then discarded, if
+	// it has changed. An empty ETag is always reported as changed.
+	VolumesChanged(
+		ctx Context,
+		sinceETag string) (bool, string, error)
+
+	// VolumesByService returns a list of all Volumes for a service. An
+	// ErrServiceNotFound is returned if the service does not exist, while a
+	// service with no volumes returns an empty map.
 	VolumesByService(
 		ctx Context,
 		service string,
 		attachments bool) (VolumeMap, error)
 
-	// VolumeInspect gets information about a single volume.
+	// VolumeInspect gets information about a single volume. If the client
+	// is configured to treat a missing resource as nil then a nil volume and
+	// a nil error are returned when the volume does not exist.
 	VolumeInspect(
 		ctx Context,
 		service, volumeID string,
@@ -143,7 +447,10 @@ type APIClient interface {
 		ctx Context,
 		service, volumeID string) error
 
-	// VolumeAttach attaches a single volume.
+	// VolumeAttach attaches a single volume. If the volume is already
+	// attached to the instance in the context and the request does not set
+	// Force then the volume is returned, without an attach token, and no
+	// attach operation is sent to the server.
 	VolumeAttach(
 		ctx Context,
 		service string,
@@ -175,6 +482,24 @@ type APIClient interface {
 		volumeID string,
 		request *VolumeSnapshotRequest) (*Snapshot, error)
 
+	// VolumeExport streams an archive of a volume's contents to the provided
+	// writer. If the service's driver cannot export volumes then an
+	// ErrCapabilityNotImplemented error is returned.
+	VolumeExport(
+		ctx Context,
+		service, volumeID string,
+		w io.Writer) error
+
+	// VolumeImport streams a volume's contents from the provided reader. If
+	// the size is negative the length of the stream is unknown and the data
+	// is sent with chunked transfer encoding. If the service's driver cannot
+	// import volumes then an ErrCapabilityNotImplemented error is returned.
+	VolumeImport(
+		ctx Context,
+		service, volumeID string,
+		r io.Reader,
+		size int64) error
+
 	// Snapshots returns a list of all Snapshots for all
 	Snapshots(ctx Context) (ServiceSnapshotMap, error)
 
@@ -182,7 +507,9 @@ type APIClient interface {
 	SnapshotsByService(
 		ctx Context, service string) (SnapshotMap, error)
 
-	// SnapshotInspect gets information about a single snapshot.
+	// SnapshotInspect gets information about a single snapshot. If the
+	// client is configured to treat a missing resource as nil then a nil
+	// snapshot and a nil error are returned when the snapshot does not exist.
 	SnapshotInspect(
 		ctx Context,
 		service, snapshotID string) (*Snapshot, error)
@@ -81,6 +81,132 @@ const (
 	// ConfigExecutorNoDownload is a config key.
 	ConfigExecutorNoDownload = ConfigRoot + ".executor.disableDownload"
 
+	// ConfigClientHTTP is a config key.
+	ConfigClientHTTP = ConfigClient + ".http"
+
+	// ConfigClientHTTPAcceptLanguage is a config key.
+	ConfigClientHTTPAcceptLanguage = ConfigClientHTTP + ".acceptLanguage"
+
+	// ConfigClientHTTPCompressThreshold is a config key.
+	ConfigClientHTTPCompressThreshold = ConfigClientHTTP + ".compressThreshold"
+
+	// ConfigClientHTTPCompression is a config key.
+	ConfigClientHTTPCompression = ConfigClientHTTP + ".compression"
+
+	// ConfigClientHTTPMaxRetries is a config key.
+	ConfigClientHTTPMaxRetries = ConfigClientHTTP + ".maxRetries"
+
+	// ConfigClientHTTPRetryBackoff is a config key.
+	ConfigClientHTTPRetryBackoff = ConfigClientHTTP + ".retryBackoff"
+
+	// ConfigClientHTTPRetryCodes is a config key.
+	ConfigClientHTTPRetryCodes = ConfigClientHTTP + ".retryCodes"
+
+	// ConfigClientHTTPRetryNonIdempotent is a config key.
+	ConfigClientHTTPRetryNonIdempotent = ConfigClientHTTP +
+		".retryNonIdempotent"
+
+	// ConfigClientHTTPDecodeTimeout is a config key.
+	ConfigClientHTTPDecodeTimeout = ConfigClientHTTP + ".decodeTimeout"
+
+	// ConfigClientHTTPHedgeDelay is a config key.
+	ConfigClientHTTPHedgeDelay = ConfigClientHTTP + ".hedgeDelay"
+
+	// ConfigClientHTTPTimeout is a config key.
+	ConfigClientHTTPTimeout = ConfigClientHTTP + ".timeout"
+
+	// ConfigClientHTTPMaxDataAge is a config key.
+	ConfigClientHTTPMaxDataAge = ConfigClientHTTP + ".maxDataAge"
+
+	// ConfigClientHTTPKeepAlive is a config key.
+	ConfigClientHTTPKeepAlive = ConfigClientHTTP + ".keepAlive"
+
+	// ConfigClientHTTPFollowLeaderRedirects is a config key.
+	ConfigClientHTTPFollowLeaderRedirects = ConfigClientHTTP +
+		".followLeaderRedirects"
+
+	// ConfigClientHTTPFailoverWarmup is a config key.
+	ConfigClientHTTPFailoverWarmup = ConfigClientHTTP + ".failoverWarmup"
+
+	// ConfigClientHTTPMaxConcurrentDials is a config key.
+	ConfigClientHTTPMaxConcurrentDials = ConfigClientHTTP +
+		".maxConcurrentDials"
+
+	// ConfigClientHTTPBackpressureMode is a config key.
+	ConfigClientHTTPBackpressureMode = ConfigClientHTTP + ".backpressuremode"
+
+	// ConfigClientHTTPMaxIdleConnsPerHost is a config key.
+	ConfigClientHTTPMaxIdleConnsPerHost = ConfigClientHTTP +
+		".maxIdleConnsPerHost"
+
+	// ConfigClientHTTPIdleConnTimeout is a config key.
+	ConfigClientHTTPIdleConnTimeout = ConfigClientHTTP + ".idleConnTimeout"
+
+	// ConfigClientHTTPTraceConnections is a config key.
+	ConfigClientHTTPTraceConnections = ConfigClientHTTP + ".traceConnections"
+
+	// ConfigClientHTTPRecordFile is a config key.
+	ConfigClientHTTPRecordFile = ConfigClientHTTP + ".recordFile"
+
+	// ConfigClientHTTPReplayFile is a config key.
+	ConfigClientHTTPReplayFile = ConfigClientHTTP + ".replayFile"
+
+	// ConfigClientServiceAliases is a config key.
+	ConfigClientServiceAliases = ConfigClient + ".serviceAliases"
+
+	// ConfigClientNotFoundAsNil is a config key.
+	ConfigClientNotFoundAsNil = ConfigClient + ".notFoundAsNil"
+
+	// ConfigClientTLSFallbackPlain is a config key.
+	ConfigClientTLSFallbackPlain = ConfigClient + ".tls.fallbackplain"
+
+	// ConfigClientTLSSessionCacheSize is a config key.
+	ConfigClientTLSSessionCacheSize = ConfigClient + ".tls.sessioncachesize"
+
+	// ConfigClientMaxVolumeSize is a config key.
+	ConfigClientMaxVolumeSize = ConfigClient + ".maxvolumesize"
+
+	// ConfigClientMaxVolumesInResult is a config key.
+	ConfigClientMaxVolumesInResult = ConfigClient + ".maxvolumesinresult"
+
+	// ConfigClientBulkConcurrency is a config key.
+	ConfigClientBulkConcurrency = ConfigClient + ".bulkConcurrency"
+
+	// ConfigClientReconcileAttach is a config key.
+	ConfigClientReconcileAttach = ConfigClient + ".reconcileAttach"
+
+	// ConfigClientAutoDetachOnClose is a config key.
+	ConfigClientAutoDetachOnClose = ConfigClient + ".autoDetachOnClose"
+
+	// ConfigClientLazyDial is a config key.
+	ConfigClientLazyDial = ConfigClient + ".lazydial"
+
+	// ConfigClientAuditFile is a config key.
+	ConfigClientAuditFile = ConfigClient + ".audit.file"
+
+	// ConfigClientVolumeNamePrefix is a config key.
+	ConfigClientVolumeNamePrefix = ConfigClient + ".volumeNamePrefix"
+
+	// ConfigClientNameCaseFold is a config key.
+	ConfigClientNameCaseFold = ConfigClient + ".namecasefold"
+
+	// ConfigClientExpvar is a config key.
+	ConfigClientExpvar = ConfigClient + ".expvar"
+
+	// ConfigClientHealthWindow is a config key.
+	ConfigClientHealthWindow = ConfigClient + ".health.window"
+
+	// ConfigClientHealthMinRequests is a config key.
+	ConfigClientHealthMinRequests = ConfigClient + ".health.minRequests"
+
+	// ConfigClientHealthDegradedPercent is a config key.
+	ConfigClientHealthDegradedPercent = ConfigClient +
+		".health.degradedPercent"
+
+	// ConfigClientHealthRecoveredPercent is a config key.
+	ConfigClientHealthRecoveredPercent = ConfigClient +
+		".health.recoveredPercent"
+
 	// ConfigClientCacheInstanceID is a config key.
 	ConfigClientCacheInstanceID = ConfigClient + ".cache.instanceID"
 
@@ -1,6 +1,10 @@
 package types
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/akutz/goof"
 )
 
@@ -8,6 +12,240 @@ import (
 // a function is not implemented.
 var ErrNotImplemented = goof.New("not implemented")
 
+// ErrCapabilityNotImplemented occurs when an operation requires a capability,
+// such as "export", that the service's driver does not implement. The error
+// unwraps to ErrNotImplemented.
+type ErrCapabilityNotImplemented struct{ goof.Goof }
+
+// Operation returns the name of the operation that was attempted.
+func (e *ErrCapabilityNotImplemented) Operation() string {
+	v, _ := e.Fields()["operation"].(string)
+	return v
+}
+
+// Capability returns the name of the capability the service lacks.
+func (e *ErrCapabilityNotImplemented) Capability() string {
+	v, _ := e.Fields()["capability"].(string)
+	return v
+}
+
+// Unwrap returns ErrNotImplemented.
+func (e *ErrCapabilityNotImplemented) Unwrap() error {
+	return ErrNotImplemented
+}
+
+// DriverError is the interface implemented by errors that describe a failure
+// reported by a storage driver, such as a storage platform rejecting a
+// request. An operation that fails with a driver error should not be retried
+// without first addressing the cause of the error.
+type DriverError interface {
+	error
+
+	// DriverError marks the error as a driver error.
+	DriverError()
+
+	// Code returns the storage platform's error code, if any.
+	Code() string
+}
+
+// TransportError is the interface implemented by errors that occur while
+// communicating with a libStorage server, such as a refused connection.
+type TransportError interface {
+	error
+
+	// Temporary returns a flag indicating whether the error is temporary and
+	// the operation may be retried.
+	Temporary() bool
+
+	// Timeout returns a flag indicating whether the error is a timeout.
+	Timeout() bool
+}
+
+// HTTPError occurs when a server responds to a request with an error status.
+// It is a goof.HTTPError, so the status is also available via Status(). The
+// more specific errors for a status, such as a DriverError for a 500 with an
+// error code or a ValidationError, embed and unwrap to the HTTPError.
+type HTTPError struct {
+	goof.HTTPError
+	RetryHistory
+
+	// StatusCode is the response's HTTP status code.
+	StatusCode int
+
+	// Message is the error message decoded from the response body, or the
+	// status text if the body is not a libStorage error.
+	Message string
+
+	// Body is the raw response body.
+	Body []byte
+}
+
+// FieldError describes why the value of a single request field is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError occurs when the server rejects a request because the values
+// of one or more of the request's fields are invalid. The server describes
+// the invalid fields with a "fieldErrors" list of field and message pairs in
+// the error's fields.
+type ValidationError struct {
+	*HTTPError
+	Errors []FieldError
+}
+
+// Unwrap returns the HTTPError with the response's status and body.
+func (e *ValidationError) Unwrap() error {
+	return e.HTTPError
+}
+
+// FieldErrors returns the errors for the request's invalid fields.
+func (e *ValidationError) FieldErrors() []FieldError {
+	return e.Errors
+}
+
+// RetryAttempt describes a failed attempt of a request that was retried.
+type RetryAttempt struct {
+	// Err is the error with which the attempt failed.
+	Err error
+
+	// Duration is the amount of time the attempt took.
+	Duration time.Duration
+}
+
+// RetriedError is implemented by the errors with which a request can fail
+// after it was sent more than once. The error keeps the concrete type of the
+// final attempt's error, ex. *HTTPError or *ErrCanceled, and provides the
+// history of the request's attempts.
+type RetriedError interface {
+	error
+
+	// Attempts returns the failed attempts of the request, in the order in
+	// which they were sent, or nil if the request was not retried.
+	Attempts() []RetryAttempt
+
+	// Elapsed returns the amount of time from the first attempt until the
+	// request failed, including the time spent waiting between attempts.
+	Elapsed() time.Duration
+}
+
+// RetryHistory records the attempts of a request that was retried. It is
+// embedded by the errors with which a retried request can fail so that they
+// implement RetriedError.
+type RetryHistory struct {
+	attempts []RetryAttempt
+	elapsed  time.Duration
+}
+
+// Attempts returns the failed attempts of the request.
+func (h *RetryHistory) Attempts() []RetryAttempt {
+	return h.attempts
+}
+
+// Elapsed returns the amount of time the request's attempts took.
+func (h *RetryHistory) Elapsed() time.Duration {
+	return h.elapsed
+}
+
+// SetRetryHistory records the provided attempts, the last of which is the
+// final attempt.
+func (h *RetryHistory) SetRetryHistory(
+	attempts []RetryAttempt, elapsed time.Duration) {
+
+	h.attempts = attempts
+	h.elapsed = elapsed
+}
+
+// MultiError aggregates the errors of a bulk operation. The errors are
+// index-aligned with the operation's requests, and the error for a request
+// that succeeded is nil.
+type MultiError struct {
+	errs []error
+}
+
+// NewMultiError returns a new MultiError for the provided, index-aligned
+// errors, or nil if none of the errors is non-nil.
+func NewMultiError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return &MultiError{errs: errs}
+		}
+	}
+	return nil
+}
+
+// Error returns the number of failed operations and each of their errors.
+func (e *MultiError) Error() string {
+	var msgs []string
+	for i, err := range e.errs {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("%d: %v", i, err))
+		}
+	}
+	return fmt.Sprintf("%d of %d operations failed: %s",
+		len(msgs), len(e.errs), strings.Join(msgs, "; "))
+}
+
+// Errors returns the index-aligned errors of the bulk operation.
+func (e *MultiError) Errors() []error {
+	return e.errs
+}
+
+// Unwrap returns the error of the failed operation if only one failed.
+func (e *MultiError) Unwrap() error {
+	var failed error
+	for _, err := range e.errs {
+		if err == nil {
+			continue
+		}
+		if failed != nil {
+			return nil
+		}
+		failed = err
+	}
+	return failed
+}
+
+// ErrDecodeTimeout occurs when no data is received for longer than the
+// configured decode timeout while decoding a response body.
+var ErrDecodeTimeout = goof.New("decode timeout")
+
+// CancelReason describes why an operation was cancelled.
+type CancelReason string
+
+const (
+	// CancelReasonCaller indicates the caller cancelled the operation's
+	// context.
+	CancelReasonCaller CancelReason = "caller"
+
+	// CancelReasonDeadline indicates the deadline of the operation's context
+	// was exceeded.
+	CancelReasonDeadline CancelReason = "deadline"
+
+	// CancelReasonTimeout indicates the client's configured request timeout
+	// elapsed.
+	CancelReasonTimeout CancelReason = "timeout"
+)
+
+// ErrCanceled occurs when an operation is cancelled before it completes. The
+// error unwraps to the cause of the cancellation, ex. context.Canceled.
+type ErrCanceled struct {
+	goof.Goof
+	RetryHistory
+
+	// Reason describes why the operation was cancelled.
+	Reason CancelReason
+
+	// Cause is the error that caused the cancellation.
+	Cause error
+}
+
+// Unwrap returns the error that caused the cancellation.
+func (e *ErrCanceled) Unwrap() error {
+	return e.Cause
+}
+
 // ErrUnsupportedForClientType is the error that occurs when an operation is
 // invoked that is unsupported for the current client type.
 type ErrUnsupportedForClientType struct{ goof.Goof }
@@ -41,6 +279,63 @@ type ErrDriverTypeErr struct{ goof.Goof }
 // the objects for which the process did complete.
 type ErrBatchProcess struct{ goof.Goof }
 
+// ErrAmbiguousVolume occurs when a volume name that is resolved without a
+// service matches volumes in more than one service.
+type ErrAmbiguousVolume struct{ goof.Goof }
+
+// ErrDeviceInUse occurs when a volume attach requests a device name that is
+// already in use by a local device.
+type ErrDeviceInUse struct{ goof.Goof }
+
+// ErrDeviceWaitTimeout occurs when an attached volume's device does not appear
+// on the local host before the timeout elapses.
+type ErrDeviceWaitTimeout struct{ goof.Goof }
+
+// ErrDeviceUnknown occurs when the device of an attached volume cannot be
+// discovered because the server returned no attach token and the volume's
+// attachment to the local instance does not name a device.
+type ErrDeviceUnknown struct{ goof.Goof }
+
+// ErrBackpressure occurs when a call would wait for a saturated limit and
+// the client is configured to fail such calls instead.
+type ErrBackpressure struct{ goof.Goof }
+
+// ErrServiceNotFound occurs when an operation is sent to a service that is
+// not registered with the server.
+type ErrServiceNotFound struct{ goof.Goof }
+
+// ErrSocketNotFound occurs when a client dials a unix socket that does not
+// exist, which usually indicates the server is not running.
+type ErrSocketNotFound struct{ goof.Goof }
+
+// ErrNotSocket occurs when a client is configured to dial a unix socket at
+// a path that exists but is not a socket.
+type ErrNotSocket struct{ goof.Goof }
+
+// ErrUnsupportedProtocol occurs when the configured host address does not
+// use one of the protocols supported by the client.
+type ErrUnsupportedProtocol struct{ goof.Goof }
+
+// ErrNotLibStorageServer occurs when a client connects to a server that
+// responds but is not a libStorage server.
+type ErrNotLibStorageServer struct{ goof.Goof }
+
+// ErrVolumeTooLarge occurs when a request to create a volume exceeds the
+// client's configured maximum volume size.
+type ErrVolumeTooLarge struct{ goof.Goof }
+
+// ErrResultTooLarge occurs when a listing returned by the server contains more
+// items than the client's configured maximum.
+type ErrResultTooLarge struct{ goof.Goof }
+
+// ErrStaleData occurs when the data in a response is older than the client's
+// configured maximum data age, or the response does not indicate its age.
+type ErrStaleData struct{ goof.Goof }
+
+// ErrInvalidCostTag occurs when a cost allocation tag's key or value
+// contains characters that may not be sent to the server.
+type ErrInvalidCostTag struct{ goof.Goof }
+
 // ErrBadFilter occurs when a bad filter is supplied via the filter query
 // string.
 type ErrBadFilter struct{ goof.Goof }
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiError(t *testing.T) {
+
+	assert.NoError(t, NewMultiError(nil))
+	assert.NoError(t, NewMultiError([]error{nil, nil}))
+
+	err0 := errors.New("volume exists")
+	err2 := errors.New("quota exceeded")
+
+	err := NewMultiError([]error{err0, nil, err2})
+	if assert.IsType(t, &MultiError{}, err) {
+		merr := err.(*MultiError)
+		assert.EqualError(t, merr,
+			"2 of 3 operations failed: 0: volume exists; 2: quota exceeded")
+		assert.Equal(t, []error{err0, nil, err2}, merr.Errors())
+		assert.Nil(t, merr.Unwrap())
+	}
+
+	err = NewMultiError([]error{nil, err2})
+	assert.EqualError(t, err, "1 of 2 operations failed: 1: quota exceeded")
+	assert.True(t, errors.Is(err, err2))
+}
@@ -0,0 +1,114 @@
+package types
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"sync"
+
+	"github.com/akutz/goof"
+)
+
+const (
+	// FieldEncodingSuffix is appended to the name of a field to form the name
+	// of the companion field that indicates how the field's value is encoded.
+	// A field without a companion field is not encoded.
+	FieldEncodingSuffix = ".encoding"
+
+	// FieldEncodingBase64 indicates a field's value is base64 encoded.
+	FieldEncodingBase64 = "base64"
+
+	// FieldEncodingGzipBase64 indicates a field's value is gzipped and then
+	// base64 encoded.
+	FieldEncodingGzipBase64 = "gzip+base64"
+)
+
+// decodedField is a field's decoded value and the encoded value from which
+// it was decoded.
+type decodedField struct {
+	encoded string
+	decoded []byte
+}
+
+// decodedFieldsLock guards the decoded fields of all volumes.
+var decodedFieldsLock sync.Mutex
+
+// FieldBytes returns the decoded value of the volume's field with the given
+// name, or nil if the volume does not have the field. The value is decoded
+// according to the field's companion encoding field on first access and
+// cached until the field's value changes.
+func (v *Volume) FieldBytes(name string) ([]byte, error) {
+
+	encoded, ok := v.Fields[name]
+	if !ok {
+		return nil, nil
+	}
+
+	decodedFieldsLock.Lock()
+	df, ok := v.decodedFields[name]
+	decodedFieldsLock.Unlock()
+
+	if !ok || df.encoded != encoded {
+		decoded, err := DecodeFieldBytes(v.Fields, name)
+		if err != nil {
+			return nil, err
+		}
+		df = &decodedField{encoded: encoded, decoded: decoded}
+		decodedFieldsLock.Lock()
+		if v.decodedFields == nil {
+			v.decodedFields = map[string]*decodedField{}
+		}
+		v.decodedFields[name] = df
+		decodedFieldsLock.Unlock()
+	}
+
+	// the cached value is copied so the caller may modify it
+	return append([]byte(nil), df.decoded...), nil
+}
+
+// DecodeFieldBytes returns the decoded value of the field with the given
+// name, or nil if the fields do not include it. The value is decoded
+// according to the companion field named by appending FieldEncodingSuffix to
+// the field's name. A value without a companion field is returned as is.
+func DecodeFieldBytes(fields map[string]string, name string) ([]byte, error) {
+
+	value, ok := fields[name]
+	if !ok {
+		return nil, nil
+	}
+
+	switch encoding := fields[name+FieldEncodingSuffix]; encoding {
+	case "":
+		return []byte(value), nil
+	case FieldEncodingBase64:
+		buf, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, goof.WithFieldE(
+				"field", name, "error decoding base64 field", err)
+		}
+		return buf, nil
+	case FieldEncodingGzipBase64:
+		buf, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, goof.WithFieldE(
+				"field", name, "error decoding base64 field", err)
+		}
+		r, err := gzip.NewReader(bytes.NewReader(buf))
+		if err != nil {
+			return nil, goof.WithFieldE(
+				"field", name, "error decoding gzip field", err)
+		}
+		defer r.Close()
+		if buf, err = ioutil.ReadAll(r); err != nil {
+			return nil, goof.WithFieldE(
+				"field", name, "error decoding gzip field", err)
+		}
+		return buf, nil
+	default:
+		return nil, goof.WithFields(goof.Fields{
+			"field":    name,
+			"encoding": encoding,
+		}, "unsupported field encoding")
+	}
+}
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipBase64(t *testing.T, data []byte) string {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestVolumeFieldBytes(t *testing.T) {
+
+	config := []byte(`{"replicas":3,"tier":"gold"}`)
+
+	v := &Volume{
+		Fields: map[string]string{
+			"config":          gzipBase64(t, config),
+			"config.encoding": FieldEncodingGzipBase64,
+			"key":             base64.StdEncoding.EncodeToString([]byte("k0")),
+			"key.encoding":    FieldEncodingBase64,
+			"plain":           "v0",
+			"bad":             "not base64!",
+			"bad.encoding":    FieldEncodingGzipBase64,
+			"zip":             "v0",
+			"zip.encoding":    "zip",
+		},
+	}
+
+	buf, err := v.FieldBytes("config")
+	assert.NoError(t, err)
+	assert.Equal(t, config, buf)
+
+	// the decoded value is cached, and a copy is returned
+	buf[0] = 'x'
+	cached := v.decodedFields["config"]
+	buf, err = v.FieldBytes("config")
+	assert.NoError(t, err)
+	assert.Equal(t, config, buf)
+	assert.True(t, cached == v.decodedFields["config"])
+
+	// a new value is decoded again
+	v.Fields["config"] = gzipBase64(t, []byte("{}"))
+	buf, err = v.FieldBytes("config")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("{}"), buf)
+
+	buf, err = v.FieldBytes("key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("k0"), buf)
+
+	buf, err = v.FieldBytes("plain")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v0"), buf)
+
+	buf, err = v.FieldBytes("missing")
+	assert.NoError(t, err)
+	assert.Nil(t, buf)
+
+	_, err = v.FieldBytes("bad")
+	assert.EqualError(t, err, "error decoding base64 field")
+	assert.NotContains(t, v.decodedFields, "bad")
+
+	_, err = v.FieldBytes("zip")
+	assert.EqualError(t, err, "unsupported field encoding")
+
+	buf, err = (&Volume{}).FieldBytes("config")
+	assert.NoError(t, err)
+	assert.Nil(t, buf)
+}
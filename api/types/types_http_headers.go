@@ -18,4 +18,24 @@ const (
 	// for the first time. This header is provided with every response sent
 	// from the server.
 	ServerNameHeader = "Libstorage-Servername"
+
+	// RateLimitLimitHeader is the HTTP header that contains the maximum
+	// number of requests permitted in the current rate-limit window.
+	RateLimitLimitHeader = "X-Ratelimit-Limit"
+
+	// RateLimitRemainingHeader is the HTTP header that contains the number of
+	// requests remaining in the current rate-limit window.
+	RateLimitRemainingHeader = "X-Ratelimit-Remaining"
+
+	// RateLimitResetHeader is the HTTP header that contains the time (epoch)
+	// at which the current rate-limit window resets.
+	RateLimitResetHeader = "X-Ratelimit-Reset"
+
+	// DataTimestampHeader is the HTTP header that contains the time (RFC 3339)
+	// at which the data in a response was read.
+	DataTimestampHeader = "X-Data-Timestamp"
+
+	// CostTagsHeader is the HTTP header that contains the JSON object of
+	// tags the server uses to attribute a request's usage.
+	CostTagsHeader = "X-Cost-Tags"
 )
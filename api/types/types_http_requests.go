@@ -1,5 +1,11 @@
 package types
 
+import (
+	"time"
+
+	"github.com/akutz/goof"
+)
+
 // NewRequestObjFunc is a function that creates a new instance of the type to
 // which the request body is serialized.
 type NewRequestObjFunc func() interface{}
@@ -12,6 +18,49 @@ type VolumeCreateRequest struct {
 	Size             *int64                 `json:"size,omitempty"`
 	Type             *string                `json:"type,omitempty"`
 	Opts             map[string]interface{} `json:"opts,omitempty"`
+
+	// sizeBytes is the size of the volume in bytes as provided by
+	// WithSizeBytes. It is converted to gibibytes by NormalizedSize.
+	sizeBytes *int64
+}
+
+// GiB is the number of bytes in a gibibyte.
+const GiB = 1024 * 1024 * 1024
+
+// WithSizeGiB sets the size of the volume in gibibytes (GiB), the unit
+// expected by the server, and returns the request.
+func (r *VolumeCreateRequest) WithSizeGiB(size int64) *VolumeCreateRequest {
+	r.Size = &size
+	r.sizeBytes = nil
+	return r
+}
+
+// WithSizeBytes sets the size of the volume in bytes and returns the request.
+// The size is converted to gibibytes (GiB) before the request is sent and
+// must therefore be a whole number of gibibytes.
+func (r *VolumeCreateRequest) WithSizeBytes(size int64) *VolumeCreateRequest {
+	r.Size = nil
+	r.sizeBytes = &size
+	return r
+}
+
+// NormalizedSize returns the size of the volume in gibibytes (GiB), or nil if
+// no size is set. An error is returned if the size is not positive or if a
+// size in bytes is not a whole number of gibibytes.
+func (r *VolumeCreateRequest) NormalizedSize() (*int64, error) {
+	if r.sizeBytes != nil {
+		bytes := *r.sizeBytes
+		if bytes <= 0 || bytes%GiB != 0 {
+			return nil, goof.WithField(
+				"sizeBytes", bytes, "invalid volume size")
+		}
+		size := bytes / GiB
+		return &size, nil
+	}
+	if r.Size != nil && *r.Size <= 0 {
+		return nil, goof.WithField("size", *r.Size, "invalid volume size")
+	}
+	return r.Size, nil
 }
 
 // VolumeCopyRequest is the JSON body for copying a volume.
@@ -24,6 +73,52 @@ type VolumeCopyRequest struct {
 type VolumeSnapshotRequest struct {
 	SnapshotName string                 `json:"snapshotName"`
 	Opts         map[string]interface{} `json:"opts,omitempty"`
+
+	// Retention is how long a driver that supports scheduling hints should
+	// keep the snapshot. It is sent to the driver as the SnapshotRetentionOpt
+	// option, formatted as a duration such as "720h0m0s".
+	Retention *time.Duration `json:"-"`
+
+	// Schedule is the schedule, such as a cron expression, on which a driver
+	// that supports scheduling hints should create the snapshot. It is sent
+	// to the driver as the SnapshotScheduleOpt option.
+	Schedule *string `json:"-"`
+}
+
+const (
+	// SnapshotRetentionOpt is the option that contains a snapshot request's
+	// retention hint.
+	SnapshotRetentionOpt = "retention"
+
+	// SnapshotScheduleOpt is the option that contains a snapshot request's
+	// schedule hint.
+	SnapshotScheduleOpt = "schedule"
+)
+
+// OptsWithHints returns a copy of the request's options to which the request's
+// scheduling hints are added. An error is returned if the retention is
+// negative.
+func (r *VolumeSnapshotRequest) OptsWithHints() (
+	map[string]interface{}, error) {
+
+	if r.Retention == nil && r.Schedule == nil {
+		return r.Opts, nil
+	}
+	opts := map[string]interface{}{}
+	for k, v := range r.Opts {
+		opts[k] = v
+	}
+	if r.Retention != nil {
+		if *r.Retention < 0 {
+			return nil, goof.WithField(
+				"retention", *r.Retention, "invalid snapshot retention")
+		}
+		opts[SnapshotRetentionOpt] = r.Retention.String()
+	}
+	if r.Schedule != nil {
+		opts[SnapshotScheduleOpt] = *r.Schedule
+	}
+	return opts, nil
 }
 
 // VolumeAttachRequest is the JSON body for attaching a volume to an instance.
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // StorageType is the type of storage a driver provides.
 type StorageType string
 
@@ -117,6 +119,14 @@ type Snapshot struct {
 	// The size of the volume to which the snapshot belongs.
 	VolumeSize int64 `json:"volumeSize,omitempty" yaml:"volumeSize,omitempty"`
 
+	// SizeGiB is the logical size of the snapshot in gibibytes (GiB).
+	SizeGiB int64 `json:"sizeGiB,omitempty" yaml:"sizeGiB,omitempty"`
+
+	// DeltaBytes is the physical size of the snapshot in bytes, the amount
+	// of data that differs from the snapshot's parent. A nil value indicates
+	// the driver does not report the snapshot's delta.
+	DeltaBytes *int64 `json:"deltaBytes,omitempty" yaml:"deltaBytes,omitempty"`
+
 	// Fields are additional properties that can be defined for this type.
 	Fields map[string]string `json:"fields,omitempty" yaml:",omitempty"`
 }
@@ -142,6 +152,9 @@ type Volume struct {
 	// The size of the volume.
 	Size int64 `json:"size,omitempty" yaml:",omitempty"`
 
+	// The time (epoch) at which the volume was created.
+	CreatedTime int64 `json:"createdTime,omitempty" yaml:"createdTime,omitempty"`
+
 	// The volume status.
 	Status string `json:"status,omitempty" yaml:",omitempty"`
 
@@ -155,6 +168,71 @@ type Volume struct {
 
 	// Fields are additional properties that can be defined for this type.
 	Fields map[string]string `json:"fields,omitempty" yaml:",omitempty"`
+
+	// DriverFields is a pointer to the struct into which the client decoded
+	// the Fields if a struct type is registered for the volume's driver,
+	// otherwise it is nil.
+	DriverFields interface{} `json:"-" yaml:"-"`
+
+	// decodedFields caches the values decoded by FieldBytes, keyed by the
+	// field's name.
+	decodedFields map[string]*decodedField
+}
+
+// VolumeWithService is a volume and the name of the service to which the
+// volume belongs.
+type VolumeWithService struct {
+	*Volume
+
+	// Service is the name of the service to which the volume belongs.
+	Service string `json:"service" yaml:"service"`
+}
+
+// VolumeCreateResult is the result of one of the requests of a bulk volume
+// create operation.
+type VolumeCreateResult struct {
+	// Index is the index of the request to which the result belongs.
+	Index int `json:"index" yaml:"index"`
+
+	// Volume is the volume that was created, or nil if the request failed.
+	Volume *Volume `json:"volume,omitempty" yaml:"volume,omitempty"`
+
+	// Error is the error that caused the request to fail, if any.
+	Error error `json:"-" yaml:"-"`
+}
+
+// ReconcileOpts are the options used when reconciling a service's volumes.
+type ReconcileOpts struct {
+	// Prune indicates whether volumes that are not in the desired set are
+	// removed.
+	Prune bool
+}
+
+// ReconcileResult describes the changes made when reconciling a service's
+// volumes.
+type ReconcileResult struct {
+	// Created are the volumes that were created.
+	Created []*Volume `json:"created,omitempty" yaml:"created,omitempty"`
+
+	// Removed are the volumes that were removed.
+	Removed []*Volume `json:"removed,omitempty" yaml:"removed,omitempty"`
+
+	// Unchanged are the desired volumes that already existed.
+	Unchanged []*Volume `json:"unchanged,omitempty" yaml:"unchanged,omitempty"`
+}
+
+// AttachResult is the result of attaching a volume and waiting for the
+// volume's device to appear on the local host.
+type AttachResult struct {
+	// Volume is the attached volume.
+	Volume *Volume `json:"volume" yaml:"volume"`
+
+	// Token is the attach token returned by the server.
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+
+	// DevicePath is the path of the volume's device on the local host, or an
+	// empty string if the device was not discovered.
+	DevicePath string `json:"devicePath,omitempty" yaml:"devicePath,omitempty"`
 }
 
 // VolumeName returns the volume's name.
@@ -253,6 +331,61 @@ type ServiceInfo struct {
 	Driver *DriverInfo `json:"driver"`
 }
 
+// CapacityInfo is information about the aggregate storage capacity of a
+// service.
+type CapacityInfo struct {
+	// TotalBytes is the total capacity of the service in bytes.
+	TotalBytes int64 `json:"totalBytes" yaml:"totalBytes"`
+
+	// AllocatedBytes is the capacity of the service in bytes that is
+	// allocated to volumes.
+	AllocatedBytes int64 `json:"allocatedBytes" yaml:"allocatedBytes"`
+
+	// AvailableBytes is the capacity of the service in bytes that is
+	// available for new volumes.
+	AvailableBytes int64 `json:"availableBytes" yaml:"availableBytes"`
+
+	// VolumeCount is the number of volumes that belong to the service.
+	VolumeCount int64 `json:"volumeCount" yaml:"volumeCount"`
+}
+
+// TLSConnectionInfo is information about the parameters negotiated during a
+// TLS handshake.
+type TLSConnectionInfo struct {
+	// Version is the name of the negotiated TLS version, ex. TLS 1.2.
+	Version string `json:"version" yaml:"version"`
+
+	// CipherSuite is the name of the negotiated cipher suite.
+	CipherSuite string `json:"cipherSuite" yaml:"cipherSuite"`
+
+	// ServerName is the server name requested by the client, if any.
+	ServerName string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+
+	// PeerCertificates is the certificate chain presented by the server.
+	PeerCertificates []*TLSCertificateInfo `json:"peerCertificates,omitempty" yaml:"peerCertificates,omitempty"`
+}
+
+// TLSCertificateInfo is a summary of a certificate.
+type TLSCertificateInfo struct {
+	// Subject is the certificate's subject.
+	Subject string `json:"subject" yaml:"subject"`
+
+	// Issuer is the certificate's issuer.
+	Issuer string `json:"issuer" yaml:"issuer"`
+
+	// SerialNumber is the certificate's serial number.
+	SerialNumber string `json:"serialNumber" yaml:"serialNumber"`
+
+	// NotBefore is the time before which the certificate is not valid.
+	NotBefore time.Time `json:"notBefore" yaml:"notBefore"`
+
+	// NotAfter is the time after which the certificate is not valid.
+	NotAfter time.Time `json:"notAfter" yaml:"notAfter"`
+
+	// DNSNames are the certificate's DNS subject alternative names.
+	DNSNames []string `json:"dnsNames,omitempty" yaml:"dnsNames,omitempty"`
+}
+
 // DriverInfo is information about a driver.
 type DriverInfo struct {
 	// Name is the driver's name.
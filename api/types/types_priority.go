@@ -0,0 +1,18 @@
+package types
+
+// RequestPriority is the priority with which a request is admitted ahead of
+// other requests that are waiting for the client's concurrency limit.
+type RequestPriority int
+
+const (
+	// PriorityLow is the priority for background requests, such as the
+	// reads made when reconciling volumes.
+	PriorityLow RequestPriority = -1
+
+	// PriorityNormal is the priority of requests that do not have one.
+	PriorityNormal RequestPriority = 0
+
+	// PriorityHigh is the priority for critical requests, such as a
+	// user-facing volume attach.
+	PriorityHigh RequestPriority = 1
+)
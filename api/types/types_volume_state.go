@@ -0,0 +1,43 @@
+package types
+
+import "strings"
+
+// VolumeState is the normalized state of a volume, derived from the volume's
+// driver-specific status and its attachments.
+type VolumeState string
+
+const (
+	// VolumeStateAvailable is the state of a volume that is not attached and
+	// may be attached.
+	VolumeStateAvailable VolumeState = "available"
+
+	// VolumeStateAttached is the state of a volume that is attached to at
+	// least one instance.
+	VolumeStateAttached VolumeState = "attached"
+
+	// VolumeStateError is the state of a volume that the storage platform
+	// reports as failed.
+	VolumeStateError VolumeState = "error"
+
+	// VolumeStateUnknown is the state of a volume whose status is not
+	// recognized.
+	VolumeStateUnknown VolumeState = "unknown"
+)
+
+// State returns the volume's normalized state. A volume with attachments is
+// attached regardless of its status, and a volume with neither a status nor
+// attachments is available.
+func (v *Volume) State() VolumeState {
+	if len(v.Attachments) > 0 {
+		return VolumeStateAttached
+	}
+	switch strings.ToLower(v.Status) {
+	case "", "available", "online":
+		return VolumeStateAvailable
+	case "attached", "in-use":
+		return VolumeStateAttached
+	case "error", "failed":
+		return VolumeStateError
+	}
+	return VolumeStateUnknown
+}
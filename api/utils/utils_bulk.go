@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"sync"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+// VolumesCreateChan implements the VolumesCreateChan function of a
+// types.APIClient using the client's VolumeCreate function. At most
+// concurrency requests are in progress at once, and a value less than one
+// sends the requests one at a time.
+func VolumesCreateChan(
+	ctx types.Context,
+	client types.APIClient,
+	service string,
+	requests []*types.VolumeCreateRequest,
+	concurrency int) <-chan *types.VolumeCreateResult {
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// the channel is large enough to hold every result so that the senders
+	// never block on a caller that stops receiving
+	results := make(chan *types.VolumeCreateResult, len(requests))
+	go func() {
+		defer close(results)
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		sem := make(chan struct{}, concurrency)
+		for i, request := range requests {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			wg.Add(1)
+			go func(i int, request *types.VolumeCreateRequest) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				vol, err := client.VolumeCreate(ctx, service, request)
+				results <- &types.VolumeCreateResult{
+					Index:  i,
+					Volume: vol,
+					Error:  err,
+				}
+			}(i, request)
+		}
+	}()
+	return results
+}
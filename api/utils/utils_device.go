@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+var (
+	// waitForDeviceBackoff is the initial wait between device polls.
+	waitForDeviceBackoff = 100 * time.Millisecond
+
+	// waitForDeviceMaxBackoff is the maximum wait between device polls.
+	waitForDeviceMaxBackoff = 2 * time.Second
+)
+
+// WaitForDevice polls the executor's LocalDevices function with an increasing
+// backoff until the expected device appears or the context is done. The
+// function is meant to be used after a volume is attached to wait for the OS
+// to surface the volume's device before it is mounted. If the context is done
+// first, an ErrCanceled error is returned.
+func WaitForDevice(
+	ctx types.Context,
+	executor types.StorageExecutorFunctions,
+	service, expectedDevice string) error {
+
+	_, err := waitForDevice(ctx, executor, service, expectedDevice,
+		&types.LocalDevicesOpts{
+			ScanType: types.DeviceScanQuick,
+			Opts:     NewStore(),
+		})
+	return err
+}
+
+// waitForDevice is WaitForDevice with the provided options, and returns the
+// local devices in which the expected device appeared.
+func waitForDevice(
+	ctx types.Context,
+	executor types.StorageExecutorFunctions,
+	service, expectedDevice string,
+	opts *types.LocalDevicesOpts) (*types.LocalDevices, error) {
+
+	ctx = ctx.WithValue(context.ServiceKey, service)
+	backoff := waitForDeviceBackoff
+
+	for {
+		ld, err := executor.LocalDevices(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := ld.DeviceMap[expectedDevice]; ok {
+			return ld, nil
+		}
+
+		ctx.WithFields(log.Fields{
+			"device":  expectedDevice,
+			"backoff": backoff,
+		}).Debug("waiting on device")
+
+		select {
+		case <-ctx.Done():
+			return nil, newCanceledError(ctx)
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > waitForDeviceMaxBackoff {
+			backoff = waitForDeviceMaxBackoff
+		}
+	}
+}
+
+// VolumeAttachAndWait attaches a volume and then waits for the volume's device
+// to appear in the local devices so that the result includes the path of the
+// device. The device is the attach token returned by the server, or, if the
+// volume was already attached and there is no token, the device named by the
+// volume's attachment to the instance in the context. If neither is known an
+// ErrDeviceUnknown error is returned with the result, and if the device does
+// not appear before the timeout in the provided options, an
+// ErrDeviceWaitTimeout error is. The options' token is ignored and the
+// options may be nil, in which case a quick scan and the default timeout are
+// used. The default timeout is also used for options without a timeout.
+func VolumeAttachAndWait(
+	ctx types.Context,
+	client types.APIClient,
+	executor types.StorageExecutorFunctions,
+	service, volumeID string,
+	request *types.VolumeAttachRequest,
+	opts *types.WaitForDeviceOpts) (*types.AttachResult, error) {
+
+	vol, token, err := client.VolumeAttach(ctx, service, volumeID, request)
+	if err != nil {
+		return nil, err
+	}
+	result := &types.AttachResult{Volume: vol, Token: token}
+
+	device := token
+	if device == "" {
+		device = attachedDevice(ctx, vol)
+	}
+	if device == "" {
+		return result, NewDeviceUnknownError(volumeID)
+	}
+
+	waitOpts := &types.WaitForDeviceOpts{
+		LocalDevicesOpts: types.LocalDevicesOpts{
+			ScanType: types.DeviceScanQuick,
+			Opts:     NewStore(),
+		},
+		Timeout: DeviceAttachTimeout(""),
+	}
+	if opts != nil {
+		*waitOpts = *opts
+		if waitOpts.Opts == nil {
+			waitOpts.Opts = NewStore()
+		}
+		if waitOpts.Timeout <= 0 {
+			waitOpts.Timeout = DeviceAttachTimeout("")
+		}
+	}
+
+	goCtx, cancel := gocontext.WithTimeout(ctx, waitOpts.Timeout)
+	defer cancel()
+	ld, err := waitForDevice(context.New(goCtx),
+		executor, service, device, &waitOpts.LocalDevicesOpts)
+	if err != nil {
+		if errors.Is(err, gocontext.DeadlineExceeded) && ctx.Err() == nil {
+			return result, NewDeviceWaitTimeoutError(
+				volumeID, device, waitOpts.Timeout)
+		}
+		return result, err
+	}
+
+	// executors whose local devices are keyed by the device itself, rather
+	// than by the attach token, map the device to nothing
+	result.DevicePath = ld.DeviceMap[device]
+	if result.DevicePath == "" {
+		result.DevicePath = device
+	}
+	return result, nil
+}
+
+// newCanceledError returns an ErrCanceled error with the reason the context
+// is done.
+func newCanceledError(ctx types.Context) error {
+	err := ctx.Err()
+	if err == gocontext.DeadlineExceeded {
+		return NewCanceledError(types.CancelReasonDeadline, err)
+	}
+	return NewCanceledError(types.CancelReasonCaller, err)
+}
+
+// attachedDevice returns the name of the device of the volume's attachment to
+// the instance in the context, or an empty string if there is no such
+// attachment.
+func attachedDevice(ctx types.Context, vol *types.Volume) string {
+	iid, ok := context.InstanceID(ctx)
+	if !ok || vol == nil {
+		return ""
+	}
+	for _, a := range vol.Attachments {
+		if a.InstanceID != nil && a.InstanceID.ID == iid.ID {
+			return a.DeviceName
+		}
+	}
+	return ""
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+// testDeviceExecutor lists the devices in a file, one per line. A line may
+// map a volume to its device with an = between them.
+type testDeviceExecutor struct {
+	devFilePath string
+	service     string
+}
+
+func (e *testDeviceExecutor) InstanceID(
+	ctx types.Context, opts types.Store) (*types.InstanceID, error) {
+	return nil, types.ErrNotImplemented
+}
+
+func (e *testDeviceExecutor) NextDevice(
+	ctx types.Context, opts types.Store) (string, error) {
+	return "", types.ErrNotImplemented
+}
+
+func (e *testDeviceExecutor) LocalDevices(
+	ctx types.Context,
+	opts *types.LocalDevicesOpts) (*types.LocalDevices, error) {
+
+	e.service, _ = context.ServiceName(ctx)
+
+	f, err := os.Open(e.devFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ld := &types.LocalDevices{Driver: "vfs", DeviceMap: map[string]string{}}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+		ld.DeviceMap[kv[0]] = kv[1]
+	}
+	return ld, scanner.Err()
+}
+
+func newTestDeviceExecutor(t *testing.T) (*testDeviceExecutor, func()) {
+	dir, err := ioutil.TempDir("", "libstorage-dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	devFilePath := path.Join(dir, "dev")
+	if err := ioutil.WriteFile(
+		devFilePath, []byte("/dev/xvda\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &testDeviceExecutor{devFilePath: devFilePath},
+		func() { os.RemoveAll(dir) }
+}
+
+func TestWaitForDevice(t *testing.T) {
+
+	defer func(d time.Duration) { waitForDeviceBackoff = d }(
+		waitForDeviceBackoff)
+	waitForDeviceBackoff = time.Millisecond
+
+	e, cleanup := newTestDeviceExecutor(t)
+	defer cleanup()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ioutil.WriteFile(e.devFilePath, []byte("/dev/xvda\n/dev/xvdb\n"), 0644)
+	}()
+
+	start := time.Now()
+	err := WaitForDevice(context.Background(), e, "vfs", "/dev/xvdb")
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, "vfs", e.service)
+}
+
+func TestWaitForDeviceTimeout(t *testing.T) {
+
+	defer func(d time.Duration) { waitForDeviceBackoff = d }(
+		waitForDeviceBackoff)
+	waitForDeviceBackoff = time.Millisecond
+
+	e, cleanup := newTestDeviceExecutor(t)
+	defer cleanup()
+
+	goCtx, cancel := gocontext.WithTimeout(
+		gocontext.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := WaitForDevice(context.New(goCtx), e, "vfs", "/dev/xvdb")
+	assert.True(t, errors.Is(err, gocontext.DeadlineExceeded))
+	var cerr *types.ErrCanceled
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, types.CancelReasonDeadline, cerr.Reason)
+	}
+}
+
+func TestWaitForDeviceCancel(t *testing.T) {
+
+	defer func(d time.Duration) { waitForDeviceBackoff = d }(
+		waitForDeviceBackoff)
+	waitForDeviceBackoff = time.Millisecond
+
+	e, cleanup := newTestDeviceExecutor(t)
+	defer cleanup()
+
+	goCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	err := WaitForDevice(context.New(goCtx), e, "vfs", "/dev/xvdb")
+	assert.True(t, errors.Is(err, gocontext.Canceled))
+	var cerr *types.ErrCanceled
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, types.CancelReasonCaller, cerr.Reason)
+	}
+}
+
+// testAttachClient attaches volumes with the provided token and attachments.
+type testAttachClient struct {
+	types.APIClient
+	token       string
+	attachments []*types.VolumeAttachment
+}
+
+func (c *testAttachClient) VolumeAttach(
+	ctx types.Context,
+	service, volumeID string,
+	request *types.VolumeAttachRequest) (*types.Volume, string, error) {
+	return &types.Volume{ID: volumeID, Attachments: c.attachments},
+		c.token, nil
+}
+
+func TestVolumeAttachAndWait(t *testing.T) {
+
+	defer func(d time.Duration) { waitForDeviceBackoff = d }(
+		waitForDeviceBackoff)
+	waitForDeviceBackoff = time.Millisecond
+
+	e, cleanup := newTestDeviceExecutor(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	client := &testAttachClient{token: "vfs-000"}
+	opts := &types.WaitForDeviceOpts{Timeout: time.Second}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ioutil.WriteFile(e.devFilePath,
+			[]byte("/dev/xvda\nvfs-000=/dev/xvdb\n"), 0644)
+	}()
+
+	result, err := VolumeAttachAndWait(
+		ctx, client, e, "vfs", "vfs-000", nil, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "vfs-000", result.Volume.ID)
+	assert.Equal(t, "vfs-000", result.Token)
+	assert.Equal(t, "/dev/xvdb", result.DevicePath)
+	assert.Equal(t, "vfs", e.service)
+
+	// options without a timeout wait for the default timeout
+	client.token = "vfs-002"
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ioutil.WriteFile(e.devFilePath,
+			[]byte("/dev/xvda\nvfs-002=/dev/xvdd\n"), 0644)
+	}()
+	result, err = VolumeAttachAndWait(
+		ctx, client, e, "vfs", "vfs-002", nil, &types.WaitForDeviceOpts{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/xvdd", result.DevicePath)
+
+	// the device does not appear before the timeout
+	client.token = "vfs-001"
+	opts.Timeout = 20 * time.Millisecond
+	result, err = VolumeAttachAndWait(
+		ctx, client, e, "vfs", "vfs-001", nil, opts)
+	assert.IsType(t, &types.ErrDeviceWaitTimeout{}, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, "vfs-001", result.Volume.ID)
+		assert.Empty(t, result.DevicePath)
+	}
+
+	// an already attached volume has no token, so the device is the one
+	// named by the volume's attachment to the instance
+	client.token = ""
+	client.attachments = []*types.VolumeAttachment{
+		{InstanceID: &types.InstanceID{ID: "iid-001"}, DeviceName: "/dev/xvdc"},
+		{InstanceID: &types.InstanceID{ID: "iid-000"}, DeviceName: "/dev/xvda"},
+	}
+	iidCtx := ctx.WithValue(
+		context.InstanceIDKey, &types.InstanceID{ID: "iid-000"})
+	result, err = VolumeAttachAndWait(
+		iidCtx, client, e, "vfs", "vfs-000", nil, opts)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Token)
+	assert.Equal(t, "/dev/xvda", result.DevicePath)
+
+	// without a token or an attachment the device cannot be discovered
+	result, err = VolumeAttachAndWait(
+		ctx, client, e, "vfs", "vfs-000", nil, opts)
+	assert.IsType(t, &types.ErrDeviceUnknown{}, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, "vfs-000", result.Volume.ID)
+		assert.Empty(t, result.DevicePath)
+	}
+}
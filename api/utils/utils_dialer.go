@@ -0,0 +1,280 @@
+package utils
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/gotil"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+// DefaultKeepAlive is the keep-alive period used by a dialer when the
+// configured period is not a valid duration.
+const DefaultKeepAlive = 30 * time.Second
+
+// NewDialer returns a new dialer configured with the client's keep-alive
+// period. A period of zero disables keep-alive.
+func NewDialer(config gofig.Config) *net.Dialer {
+
+	keepAlive, err := time.ParseDuration(
+		config.GetString(types.ConfigClientHTTPKeepAlive))
+	if err != nil {
+		keepAlive = DefaultKeepAlive
+	}
+
+	// a negative keep-alive period disables keep-alive on the dialer
+	if keepAlive == 0 {
+		keepAlive = -1
+	}
+
+	return &net.Dialer{KeepAlive: keepAlive}
+}
+
+// DefaultIdleConnTimeout is the amount of time an idle connection is kept
+// open by a transport when the configured timeout is not a valid duration.
+const DefaultIdleConnTimeout = 90 * time.Second
+
+// NewTransport returns a new transport that establishes connections with the
+// provided dial function and keeps the client's configured number of idle
+// connections per host open for reuse until the configured idle timeout
+// elapses. An idle timeout of zero keeps idle connections open indefinitely.
+func NewTransport(
+	config gofig.Config,
+	dial func(
+		ctx gocontext.Context,
+		network, addr string) (net.Conn, error)) *http.Transport {
+
+	maxIdleConnsPerHost := config.GetInt(
+		types.ConfigClientHTTPMaxIdleConnsPerHost)
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = http.DefaultMaxIdleConnsPerHost
+	}
+
+	idleConnTimeout, err := time.ParseDuration(
+		config.GetString(types.ConfigClientHTTPIdleConnTimeout))
+	if err != nil {
+		idleConnTimeout = DefaultIdleConnTimeout
+	}
+
+	return &http.Transport{
+		DialContext:         dial,
+		DisableKeepAlives:   config.GetBool(types.ConfigHTTPDisableKeepAlive),
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeout,
+	}
+}
+
+// hostProtos are the protocols with which a client may dial the server.
+var hostProtos = []string{"tcp", "unix"}
+
+// ParseHostAddress parses a host address, such as tcp://127.0.0.1:7979 or
+// unix:///var/run/libstorage/localhost.sock, into its protocol and address.
+// An ErrUnsupportedProtocol error is returned if the address does not use one
+// of the protocols with which a client may dial the server.
+func ParseHostAddress(host string) (string, string, error) {
+	proto, addr, err := gotil.ParseAddress(host)
+	if err != nil {
+		if i := strings.Index(host, "://"); i >= 0 {
+			proto = host[:i]
+		}
+		return "", "", NewUnsupportedProtocolError(host, proto, hostProtos)
+	}
+	for _, p := range hostProtos {
+		if strings.EqualFold(proto, p) {
+			return p, addr, nil
+		}
+	}
+	return "", "", NewUnsupportedProtocolError(host, proto, hostProtos)
+}
+
+// CheckSocket returns an ErrSocketNotFound error if the protocol is unix and
+// the socket does not exist, so that dialing a server that is not running
+// fails with a clear error instead of the error returned by the system call.
+// An ErrNotSocket error is returned if the path exists but is not a socket.
+func CheckSocket(proto, addr string) error {
+	if proto != "unix" {
+		return nil
+	}
+	fi, err := os.Stat(addr)
+	if os.IsNotExist(err) {
+		return NewSocketNotFoundError(addr)
+	}
+	if err == nil && fi.Mode()&os.ModeSocket == 0 {
+		return NewNotSocketError(addr)
+	}
+	return nil
+}
+
+const (
+	// BackpressureModeBlocking causes a call that cannot proceed because a
+	// limit is saturated to wait until it can.
+	BackpressureModeBlocking = "blocking"
+
+	// BackpressureModeNonBlocking causes a call that cannot proceed because
+	// a limit is saturated to fail immediately with an ErrBackpressure.
+	BackpressureModeNonBlocking = "nonblocking"
+)
+
+// DialLimiter limits the number of connections that are established at once.
+// Dials that wait for the limit are admitted in the order of the priorities
+// of their contexts, and in the order they arrived for the same priority. A
+// nil DialLimiter does not limit dials.
+type DialLimiter struct {
+	lock        sync.Mutex
+	limit       int
+	dialing     int
+	waiting     []*dialWaiter
+	nonBlocking bool
+}
+
+// dialWaiter is a dial waiting for the limit. The ready channel is closed
+// when the dial is admitted.
+type dialWaiter struct {
+	priority types.RequestPriority
+	ready    chan struct{}
+}
+
+// NewDialLimiter returns a new dial limiter configured with the client's
+// maximum number of concurrent dials and backpressure mode, or nil if the
+// limit is disabled.
+func NewDialLimiter(config gofig.Config) *DialLimiter {
+	limit := config.GetInt(types.ConfigClientHTTPMaxConcurrentDials)
+	if limit <= 0 {
+		return nil
+	}
+	mode := config.GetString(types.ConfigClientHTTPBackpressureMode)
+	return &DialLimiter{
+		limit:       limit,
+		nonBlocking: strings.EqualFold(mode, BackpressureModeNonBlocking),
+	}
+}
+
+// Limit returns the maximum number of concurrent dials, or zero if dials are
+// not limited.
+func (l *DialLimiter) Limit() int {
+	if l == nil {
+		return 0
+	}
+	return l.limit
+}
+
+// Dial invokes the provided dial function once fewer than the maximum number
+// of concurrent dials are in progress. The context's error is returned if the
+// context is done before the dial function is invoked. In non-blocking mode
+// an ErrBackpressure is returned instead of waiting.
+func (l *DialLimiter) Dial(
+	ctx gocontext.Context,
+	dial func() (net.Conn, error)) (net.Conn, error) {
+
+	if l == nil {
+		return dial()
+	}
+	if err := l.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer l.release()
+	return dial()
+}
+
+func (l *DialLimiter) acquire(ctx gocontext.Context) error {
+
+	l.lock.Lock()
+	if l.dialing < l.limit {
+		l.dialing++
+		l.lock.Unlock()
+		return nil
+	}
+	if l.nonBlocking {
+		l.lock.Unlock()
+		return NewBackpressureError(l.limit)
+	}
+
+	// queue the dial behind those with the same or a higher priority
+	w := &dialWaiter{
+		priority: context.Priority(ctx),
+		ready:    make(chan struct{}),
+	}
+	i := len(l.waiting)
+	for i > 0 && l.waiting[i-1].priority < w.priority {
+		i--
+	}
+	l.waiting = append(l.waiting, nil)
+	copy(l.waiting[i+1:], l.waiting[i:])
+	l.waiting[i] = w
+	l.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.lock.Lock()
+	select {
+	case <-w.ready:
+		// the dial was admitted as the context was done
+		l.lock.Unlock()
+		l.release()
+	default:
+		for i, v := range l.waiting {
+			if v == w {
+				l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+				break
+			}
+		}
+		l.lock.Unlock()
+	}
+	return ctx.Err()
+}
+
+// release ends a dial, admitting the first waiting dial in its place.
+func (l *DialLimiter) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.waiting) == 0 {
+		l.dialing--
+		return
+	}
+	w := l.waiting[0]
+	l.waiting = l.waiting[1:]
+	close(w.ready)
+}
+
+// DialTLS connects to the address and performs a TLS handshake, abandoning
+// both if the context is done. If the server responds to the handshake with
+// plain HTTP and fallbackPlain is true, the connection is established again
+// without TLS and a warning is logged.
+func DialTLS(
+	ctx types.Context,
+	dialer *net.Dialer,
+	network, addr string,
+	config *tls.Config,
+	fallbackPlain bool) (net.Conn, error) {
+
+	tlsDialer := &tls.Dialer{NetDialer: dialer, Config: config}
+	conn, err := tlsDialer.DialContext(ctx, network, addr)
+	if err == nil || !fallbackPlain || !isPlainHTTPResponse(err) {
+		return conn, err
+	}
+
+	ctx.WithField("addr", addr).Warn(
+		"server does not support tls; falling back to an UNENCRYPTED " +
+			"connection")
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// isPlainHTTPResponse returns a flag indicating whether the error occurred
+// because the server responded to a TLS handshake with plain HTTP.
+func isPlainHTTPResponse(err error) bool {
+	rerr, ok := err.(tls.RecordHeaderError)
+	return ok && string(rerr.RecordHeader[:]) == "HTTP/"
+}
@@ -0,0 +1,394 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/stretchr/testify/assert"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestNewDialer(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPKeepAlive, "45s")
+	assert.Equal(t, 45*time.Second, NewDialer(config).KeepAlive)
+
+	config.Set(types.ConfigClientHTTPKeepAlive, "0")
+	assert.True(t, NewDialer(config).KeepAlive < 0)
+
+	config.Set(types.ConfigClientHTTPKeepAlive, "invalid")
+	assert.Equal(t, DefaultKeepAlive, NewDialer(config).KeepAlive)
+}
+
+func TestDialLimiter(t *testing.T) {
+
+	config := gofig.New()
+	assert.Nil(t, NewDialLimiter(config))
+
+	config.Set(types.ConfigClientHTTPMaxConcurrentDials, 3)
+	limiter := NewDialLimiter(config)
+	assert.Equal(t, 3, limiter.Limit())
+
+	var (
+		inProgress    int32
+		maxInProgress int32
+		wg            sync.WaitGroup
+	)
+
+	dial := func() (net.Conn, error) {
+		n := atomic.AddInt32(&inProgress, 1)
+		defer atomic.AddInt32(&inProgress, -1)
+		for {
+			max := atomic.LoadInt32(&maxInProgress)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInProgress, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil, nil
+	}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Dial(context.Background(), dial)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), maxInProgress)
+}
+
+// dialing returns the number of dials the limiter has admitted.
+func dialing(l *DialLimiter) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.dialing
+}
+
+func waiting(l *DialLimiter) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.waiting)
+}
+
+func TestDialLimiterPriority(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxConcurrentDials, 1)
+	limiter := NewDialLimiter(config)
+
+	release := make(chan struct{})
+	go limiter.Dial(context.Background(), func() (net.Conn, error) {
+		<-release
+		return nil, nil
+	})
+	for dialing(limiter) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var (
+		mu       sync.Mutex
+		admitted []string
+		wg       sync.WaitGroup
+	)
+
+	// queue the dials one at a time so their arrival order is known
+	queue := func(name string, priority types.RequestPriority) {
+		n := waiting(limiter)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.WithPriority(context.Background(), priority)
+			limiter.Dial(ctx, func() (net.Conn, error) {
+				mu.Lock()
+				admitted = append(admitted, name)
+				mu.Unlock()
+				return nil, nil
+			})
+		}()
+		for waiting(limiter) == n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	queue("low0", types.PriorityLow)
+	queue("low1", types.PriorityLow)
+	queue("normal", types.PriorityNormal)
+	queue("low2", types.PriorityLow)
+	queue("high", types.PriorityHigh)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t,
+		[]string{"high", "normal", "low0", "low1", "low2"}, admitted)
+	assert.Equal(t, 0, dialing(limiter))
+}
+
+func TestDialLimiterCancel(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxConcurrentDials, 1)
+	limiter := NewDialLimiter(config)
+
+	release := make(chan struct{})
+	go limiter.Dial(context.Background(), func() (net.Conn, error) {
+		<-release
+		return nil, nil
+	})
+	defer close(release)
+
+	for dialing(limiter) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	goCtx, cancel := gocontext.WithTimeout(
+		gocontext.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	dialed := false
+	_, err := limiter.Dial(goCtx, func() (net.Conn, error) {
+		dialed = true
+		return nil, nil
+	})
+	assert.Equal(t, gocontext.DeadlineExceeded, err)
+	assert.False(t, dialed)
+}
+
+func TestDialLimiterBackpressure(t *testing.T) {
+
+	config := gofig.New()
+	config.Set(types.ConfigClientHTTPMaxConcurrentDials, 1)
+	config.Set(types.ConfigClientHTTPBackpressureMode, "nonblocking")
+	nonBlocking := NewDialLimiter(config)
+	config.Set(types.ConfigClientHTTPBackpressureMode, "blocking")
+	blocking := NewDialLimiter(config)
+
+	const hold = 50 * time.Millisecond
+	saturate := func(limiter *DialLimiter) {
+		go limiter.Dial(context.Background(), func() (net.Conn, error) {
+			time.Sleep(hold)
+			return nil, nil
+		})
+		for dialing(limiter) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	dialed := false
+	dial := func() (net.Conn, error) {
+		dialed = true
+		return nil, nil
+	}
+
+	saturate(nonBlocking)
+	start := time.Now()
+	_, err := nonBlocking.Dial(context.Background(), dial)
+	assert.IsType(t, &types.ErrBackpressure{}, err)
+	assert.True(t, time.Since(start) < hold)
+	assert.False(t, dialed)
+
+	saturate(blocking)
+	start = time.Now()
+	_, err = blocking.Dial(context.Background(), dial)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= hold/2)
+	assert.True(t, dialed)
+}
+
+func TestDialTLSFallbackPlain(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	logBuf := &bytes.Buffer{}
+	logOut := log.StandardLogger().Out
+	log.StandardLogger().Out = logBuf
+	defer func() { log.StandardLogger().Out = logOut }()
+
+	ctx := context.Background()
+	dialer := &net.Dialer{}
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+
+	conn, err := DialTLS(ctx, dialer, "tcp", addr, tlsConfig, false)
+	assert.Error(t, err)
+	assert.Nil(t, conn)
+	assert.NotContains(t, logBuf.String(), "UNENCRYPTED")
+
+	conn, err = DialTLS(ctx, dialer, "tcp", addr, tlsConfig, true)
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		_, ok := conn.(*tls.Conn)
+		assert.False(t, ok)
+		assert.Contains(t, logBuf.String(), "UNENCRYPTED")
+	}
+
+	// a TLS server is never dialed without TLS
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	conn, err = DialTLS(ctx, dialer, "tcp",
+		tlsServer.Listener.Addr().String(), tlsConfig, true)
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		_, ok := conn.(*tls.Conn)
+		assert.True(t, ok)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+
+	config := gofig.New()
+	transport := NewTransport(config, nil)
+	assert.Equal(t,
+		http.DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, DefaultIdleConnTimeout, transport.IdleConnTimeout)
+	assert.False(t, transport.DisableKeepAlives)
+
+	config.Set(types.ConfigClientHTTPMaxIdleConnsPerHost, 8)
+	config.Set(types.ConfigClientHTTPIdleConnTimeout, "0")
+	config.Set(types.ConfigHTTPDisableKeepAlive, true)
+	transport = NewTransport(config, nil)
+	assert.Equal(t, 8, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Duration(0), transport.IdleConnTimeout)
+	assert.True(t, transport.DisableKeepAlives)
+}
+
+// BenchmarkSequentialGETs reports the number of TLS handshakes performed for
+// 100 sequential GET requests with and without connection reuse.
+func BenchmarkSequentialGETs(b *testing.B) {
+
+	var handshakes int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&handshakes, 1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	for _, disableKeepAlive := range []bool{false, true} {
+		name := "reuse"
+		if disableKeepAlive {
+			name = "noReuse"
+		}
+		b.Run(name, func(b *testing.B) {
+
+			config := gofig.New()
+			config.Set(types.ConfigHTTPDisableKeepAlive, disableKeepAlive)
+			tlsDialer := &tls.Dialer{
+				Config: &tls.Config{InsecureSkipVerify: true},
+			}
+			transport := NewTransport(config, func(
+				ctx gocontext.Context,
+				network, _ string) (net.Conn, error) {
+				return tlsDialer.DialContext(ctx, network, addr)
+			})
+			defer transport.CloseIdleConnections()
+			hc := &http.Client{Transport: transport}
+
+			atomic.StoreInt64(&handshakes, 0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < 100; j++ {
+					res, err := hc.Get("http://libstorage-server/")
+					if err != nil {
+						b.Fatal(err)
+					}
+					res.Body.Close()
+				}
+			}
+			b.ReportMetric(
+				float64(atomic.LoadInt64(&handshakes))/float64(b.N),
+				"handshakes/op")
+		})
+	}
+}
+
+func TestParseHostAddress(t *testing.T) {
+
+	proto, addr, err := ParseHostAddress("tcp://127.0.0.1:7979")
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp", proto)
+	assert.Equal(t, "127.0.0.1:7979", addr)
+
+	proto, addr, err = ParseHostAddress("unix:///var/run/libstorage.sock")
+	assert.NoError(t, err)
+	assert.Equal(t, "unix", proto)
+	assert.Equal(t, "/var/run/libstorage.sock", addr)
+
+	for host, proto := range map[string]string{
+		"http://127.0.0.1:7979": "http",
+		"udp://127.0.0.1:7979":  "udp",
+		"127.0.0.1:7979":        "",
+	} {
+		_, _, err := ParseHostAddress(host)
+		if assert.IsType(t, &types.ErrUnsupportedProtocol{}, err, host) {
+			fields := err.(goof.Goof).Fields()
+			assert.Equal(t, host, fields["host"])
+			assert.Equal(t, proto, fields["proto"])
+			assert.Equal(t, []string{"tcp", "unix"}, fields["supported"])
+		}
+	}
+}
+
+func TestCheckSocket(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "libstorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "libstorage.txt")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = CheckSocket("unix", file)
+	if assert.Error(t, err) {
+		_, ok := err.(*types.ErrNotSocket)
+		assert.True(t, ok)
+		assert.Equal(t, file, err.(goof.Goof).Fields()["path"])
+	}
+
+	sock := path.Join(dir, "libstorage.sock")
+	err = CheckSocket("unix", sock)
+	if assert.Error(t, err) {
+		_, ok := err.(*types.ErrSocketNotFound)
+		assert.True(t, ok)
+		assert.Contains(t, err.Error(), "server running")
+		assert.Equal(t, sock, err.(goof.Goof).Fields()["path"])
+	}
+
+	assert.NoError(t, CheckSocket("tcp", "127.0.0.1:7979"))
+
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	assert.NoError(t, CheckSocket("unix", sock))
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/akutz/goof"
 
 	"github.com/emccode/libstorage/api/types"
@@ -64,3 +68,140 @@ func NewBadFilterErr(filter string, err error) error {
 	return &types.ErrBadFilter{Goof: goof.WithFieldE(
 		"filter", filter, "bad filter", err)}
 }
+
+// NewDeviceInUseError returns a new ErrDeviceInUse error.
+func NewDeviceInUseError(deviceName, localDevice string) error {
+	return &types.ErrDeviceInUse{Goof: goof.WithFields(goof.Fields{
+		"deviceName":  deviceName,
+		"localDevice": localDevice,
+	}, "device in use")}
+}
+
+// NewDeviceWaitTimeoutError returns a new ErrDeviceWaitTimeout error.
+func NewDeviceWaitTimeoutError(
+	volumeID, token string, timeout time.Duration) error {
+	return &types.ErrDeviceWaitTimeout{Goof: goof.WithFields(goof.Fields{
+		"volumeID": volumeID,
+		"token":    token,
+		"timeout":  timeout,
+	}, "timed out waiting for device")}
+}
+
+// NewDeviceUnknownError returns a new ErrDeviceUnknown error.
+func NewDeviceUnknownError(volumeID string) error {
+	return &types.ErrDeviceUnknown{Goof: goof.WithField(
+		"volumeID", volumeID,
+		"attached volume has no attach token or attached device")}
+}
+
+// NewBackpressureError returns a new ErrBackpressure error.
+func NewBackpressureError(limit int) error {
+	return &types.ErrBackpressure{Goof: goof.WithField(
+		"limit", limit, "limit saturated; try again later")}
+}
+
+// NewServiceNotFoundError returns a new ErrServiceNotFound error.
+func NewServiceNotFoundError(service string, err error) error {
+	return &types.ErrServiceNotFound{Goof: goof.WithFieldE(
+		"service", service, "service not found", err)}
+}
+
+// NewSocketNotFoundError returns a new ErrSocketNotFound error.
+func NewSocketNotFoundError(path string) error {
+	return &types.ErrSocketNotFound{Goof: goof.WithField(
+		"path", path, "socket not found; is the libStorage server running?")}
+}
+
+// NewNotSocketError returns a new ErrNotSocket error.
+func NewNotSocketError(path string) error {
+	return &types.ErrNotSocket{Goof: goof.WithField(
+		"path", path, "path is not a unix socket")}
+}
+
+// NewUnsupportedProtocolError returns a new ErrUnsupportedProtocol error.
+func NewUnsupportedProtocolError(
+	host, proto string, supported []string) error {
+
+	return &types.ErrUnsupportedProtocol{Goof: goof.WithFields(goof.Fields{
+		"host":      host,
+		"proto":     proto,
+		"supported": supported,
+	}, "unsupported host protocol")}
+}
+
+// NewNotLibStorageServerError returns a new ErrNotLibStorageServer error.
+func NewNotLibStorageServerError(host string, err error) error {
+	return &types.ErrNotLibStorageServer{Goof: goof.WithFieldE(
+		"host", host, "not a libStorage server", err)}
+}
+
+// NewCanceledError returns a new ErrCanceled error.
+func NewCanceledError(reason types.CancelReason, cause error) error {
+	return &types.ErrCanceled{
+		Goof: goof.WithFieldE(
+			"reason", reason, "operation canceled", cause),
+		Reason: reason,
+		Cause:  cause,
+	}
+}
+
+// NewVolumeTooLargeError returns a new ErrVolumeTooLarge error.
+func NewVolumeTooLargeError(size, maxSize int64) error {
+	return &types.ErrVolumeTooLarge{Goof: goof.WithFields(goof.Fields{
+		"size":    size,
+		"maxSize": maxSize,
+	}, "volume size exceeds maximum")}
+}
+
+// NewResultTooLargeError returns a new ErrResultTooLarge error.
+func NewResultTooLargeError(count, maxCount int) error {
+	return &types.ErrResultTooLarge{Goof: goof.WithFields(goof.Fields{
+		"count":    count,
+		"maxCount": maxCount,
+	}, "result exceeds maximum size; list volumes by service instead")}
+}
+
+// NewCapabilityNotImplementedError returns a new ErrCapabilityNotImplemented
+// error. The detail is the server's description of the error, if any.
+func NewCapabilityNotImplementedError(
+	service, operation, capability, detail string) error {
+
+	return &types.ErrCapabilityNotImplemented{Goof: goof.WithFields(
+		goof.Fields{
+			"service":    service,
+			"operation":  operation,
+			"capability": capability,
+			"detail":     detail,
+		}, fmt.Sprintf("capability not implemented: %s", capability))}
+}
+
+// NewStaleDataError returns a new ErrStaleData error. A zero timestamp
+// indicates the response did not include one.
+func NewStaleDataError(timestamp time.Time, maxAge time.Duration) error {
+	if timestamp.IsZero() {
+		return &types.ErrStaleData{Goof: goof.WithField(
+			"maxAge", maxAge, "response has no data timestamp")}
+	}
+	return &types.ErrStaleData{Goof: goof.WithFields(goof.Fields{
+		"timestamp": timestamp,
+		"age":       time.Since(timestamp),
+		"maxAge":    maxAge,
+	}, "response data is stale")}
+}
+
+// NewInvalidCostTagError returns a new ErrInvalidCostTag error.
+func NewInvalidCostTagError(key, value string) error {
+	return &types.ErrInvalidCostTag{Goof: goof.WithFields(goof.Fields{
+		"key":   key,
+		"value": value,
+	}, "invalid cost tag")}
+}
+
+// NewAmbiguousVolumeError returns a new ErrAmbiguousVolume error.
+func NewAmbiguousVolumeError(name string, services []string) error {
+	return &types.ErrAmbiguousVolume{Goof: goof.WithFields(goof.Fields{
+		"volumeName": name,
+		"services":   services,
+	}, fmt.Sprintf("volume name matches multiple services: %s",
+		strings.Join(services, ", ")))}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/akutz/goof"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+// Reconcile implements the Reconcile function of a types.APIClient using the
+// client's other functions.
+func Reconcile(
+	ctx types.Context,
+	client types.APIClient,
+	service string,
+	desired []*types.VolumeCreateRequest,
+	opts *types.ReconcileOpts) (*types.ReconcileResult, error) {
+
+	if opts == nil {
+		opts = &types.ReconcileOpts{}
+	}
+
+	desiredNames := map[string]bool{}
+	for _, request := range desired {
+		if desiredNames[request.Name] {
+			return nil, goof.WithField(
+				"volumeName", request.Name, "duplicate desired volume")
+		}
+		desiredNames[request.Name] = true
+	}
+
+	actual, err := client.VolumesByService(ctx, service, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// sort the actual volumes so that changes are made in a stable order
+	var volumeIDs []string
+	actualNames := map[string]*types.Volume{}
+	for id, v := range actual {
+		volumeIDs = append(volumeIDs, id)
+		if _, ok := actualNames[v.Name]; !ok {
+			actualNames[v.Name] = v
+		}
+	}
+	sort.Strings(volumeIDs)
+
+	result := &types.ReconcileResult{}
+
+	for _, request := range desired {
+		if v, ok := actualNames[request.Name]; ok {
+			result.Unchanged = append(result.Unchanged, v)
+			continue
+		}
+		v, err := client.VolumeCreate(ctx, service, request)
+		if err != nil {
+			return nil, NewBatchProcessErr(result, err)
+		}
+		result.Created = append(result.Created, v)
+	}
+
+	if !opts.Prune {
+		return result, nil
+	}
+
+	for _, id := range volumeIDs {
+		v := actual[id]
+		if desiredNames[v.Name] {
+			continue
+		}
+		if err := client.VolumeRemove(ctx, service, id); err != nil {
+			return nil, NewBatchProcessErr(result, err)
+		}
+		result.Removed = append(result.Removed, v)
+	}
+
+	return result, nil
+}
+
+// FoldVolumeNames returns copies of the requests with the volume names
+// lowercased, for clients that normalize the case of volume names.
+func FoldVolumeNames(
+	requests []*types.VolumeCreateRequest) []*types.VolumeCreateRequest {
+
+	folded := make([]*types.VolumeCreateRequest, len(requests))
+	for i, request := range requests {
+		r := *request
+		r.Name = strings.ToLower(r.Name)
+		folded[i] = &r
+	}
+	return folded
+}
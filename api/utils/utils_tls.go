@@ -57,9 +57,19 @@ func ParseTLSConfig(
 	}
 	f(types.ConfigTLSCertFile, certFile)
 
+	// a key pair that cannot be loaded, such as a key that does not match the
+	// certificate, fails here instead of being rejected by the peer during
+	// the handshake
 	cer, err := tls.LoadX509KeyPair(certFile, keyFile)
 	if err != nil {
-		return nil, err
+		return nil, goof.WithFieldsE(goof.Fields{
+			"certFile": certFile,
+			"keyFile":  keyFile,
+		}, "error loading key pair", err)
+	}
+	if leaf, err := x509.ParseCertificate(cer.Certificate[0]); err == nil {
+		cer.Leaf = leaf
+		f("certSubject", leaf.Subject.String())
 	}
 
 	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cer}}
@@ -107,10 +117,24 @@ func ParseTLSConfig(
 		}
 
 		certPool := x509.NewCertPool()
-		certPool.AppendCertsFromPEM(buf)
+		if !certPool.AppendCertsFromPEM(buf) {
+			return nil, goof.WithField(
+				"path", trustedCertsFile, "no certificates in trust file")
+		}
 		tlsConfig.RootCAs = certPool
 		tlsConfig.ClientCAs = certPool
 	}
 
 	return tlsConfig, nil
 }
+
+// NewClientSessionCache returns a TLS session cache sized according to the
+// client's configuration. A size of zero results in a cache with the default
+// size; a negative size disables the cache and nil is returned.
+func NewClientSessionCache(config gofig.Config) tls.ClientSessionCache {
+	size := config.GetInt(types.ConfigClientTLSSessionCacheSize)
+	if size < 0 {
+		return nil
+	}
+	return tls.NewLRUClientSessionCache(size)
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/types"
+)
+
+func TestNewClientSessionCache(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	// dial sends a request over a new connection so that any session ticket
+	// sent by the server after the handshake is received
+	dial := func(tlsConfig *tls.Config) bool {
+		conn, err := tls.Dial("tcp", addr, tlsConfig)
+		if err != nil {
+			assert.NoError(t, err)
+			t.FailNow()
+		}
+		defer conn.Close()
+		conn.Write([]byte(
+			"GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+		ioutil.ReadAll(conn)
+		return conn.ConnectionState().DidResume
+	}
+
+	config := gofig.New()
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: true,
+		ClientSessionCache: NewClientSessionCache(config),
+	}
+	assert.NotNil(t, tlsConfig.ClientSessionCache)
+	assert.False(t, dial(tlsConfig))
+	assert.True(t, dial(tlsConfig))
+
+	config.Set(types.ConfigClientTLSSessionCacheSize, -1)
+	tlsConfig = &tls.Config{
+		InsecureSkipVerify: true,
+		ClientSessionCache: NewClientSessionCache(config),
+	}
+	assert.Nil(t, tlsConfig.ClientSessionCache)
+	assert.False(t, dial(tlsConfig))
+	assert.False(t, dial(tlsConfig))
+}
+
+// writeKeyPair writes a self-signed certificate with the provided common name
+// and its private key to PEM files in the directory.
+func writeKeyPair(
+	t *testing.T, dir, commonName string) (certFile, keyFile string) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = path.Join(dir, commonName+".crt")
+	keyFile = path.Join(dir, commonName+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, file, blockType string, der []byte) {
+	buf := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(file, buf, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTLSConfigClientCert(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "libstorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeKeyPair(t, dir, "client")
+	otherCertFile, _ := writeKeyPair(t, dir, "other")
+
+	newConfig := func(certFile, keyFile, trustedCertsFile string) gofig.Config {
+		config := gofig.New()
+		config.Set("libstorage.client.tls.certFile", certFile)
+		config.Set("libstorage.client.tls.keyFile", keyFile)
+		config.Set("libstorage.client.tls.trustedCertsFile", trustedCertsFile)
+		return config
+	}
+
+	fields := log.Fields{}
+	tlsConfig, err := ParseTLSConfig(
+		newConfig(certFile, keyFile, otherCertFile),
+		fields, "libstorage.client")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Equal(t, "CN=client", fields["certSubject"])
+
+	// the key does not match the certificate
+	_, err = ParseTLSConfig(
+		newConfig(otherCertFile, keyFile, otherCertFile),
+		nil, "libstorage.client")
+	if assert.Error(t, err) {
+		assert.Equal(t, "error loading key pair", err.Error())
+	}
+
+	// the trust file is not a PEM bundle
+	_, err = ParseTLSConfig(
+		newConfig(certFile, keyFile, keyFile), nil, "libstorage.client")
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "no certificates"))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/gofig"
@@ -24,6 +25,12 @@ type client struct {
 	serviceCache    *lss
 	lsxCache        *lss
 	instanceIDCache types.Store
+
+	// lazyDial indicates whether dialing the server is deferred until the
+	// first storage operation.
+	lazyDial  bool
+	dialed    bool
+	dialedMtx sync.Mutex
 }
 
 func (c *client) isController() bool {
@@ -62,7 +69,7 @@ func (c *client) dial(ctx types.Context) error {
 	for service, _ := range svcInfos {
 		ctx := c.ctx.WithValue(context.ServiceKey, service)
 		ctx.Info("initializing instance ID cache")
-		if _, err := c.InstanceID(ctx, store); err != nil {
+		if _, err := c.instanceID(ctx, store); err != nil {
 			return err
 		}
 	}
@@ -70,6 +77,28 @@ func (c *client) dial(ctx types.Context) error {
 	return nil
 }
 
+// ensureDialed dials the server if dialing is deferred and the server has not
+// been dialed successfully. A failed dial is attempted again by the next
+// operation.
+func (c *client) ensureDialed(ctx types.Context) error {
+	if !c.lazyDial {
+		return nil
+	}
+
+	c.dialedMtx.Lock()
+	defer c.dialedMtx.Unlock()
+
+	if c.dialed {
+		return nil
+	}
+	if err := c.dial(c.requireCtx(ctx)); err != nil {
+		return err
+	}
+	c.dialed = true
+	c.ctx.Info("successefully dialed libStorage server")
+	return nil
+}
+
 func getHost(proto, lAddr string, tlsConfig *tls.Config) string {
 	if tlsConfig != nil && tlsConfig.ServerName != "" {
 		return tlsConfig.ServerName
@@ -43,6 +43,14 @@ func (c *client) Root(
 	return c.APIClient.Root(c.requireCtx(ctx))
 }
 
+func (c *client) Close(ctx types.Context) error {
+	return c.APIClient.Close(c.requireCtx(ctx))
+}
+
+func (c *client) Verify(ctx types.Context) error {
+	return c.APIClient.Verify(c.requireCtx(ctx))
+}
+
 func (c *client) Services(
 	ctx types.Context) (map[string]*types.ServiceInfo, error) {
 
@@ -65,6 +73,20 @@ func (c *client) ServiceInspect(
 	return c.APIClient.ServiceInspect(ctx, service)
 }
 
+func (c *client) ServiceCapacity(
+	ctx types.Context, service string) (*types.CapacityInfo, error) {
+
+	ctx = c.withInstanceID(c.requireCtx(ctx), service)
+	return c.APIClient.ServiceCapacity(ctx, service)
+}
+
+func (c *client) WaitForService(
+	ctx types.Context, service string) (*types.ServiceInfo, error) {
+
+	ctx = c.withInstanceID(c.requireCtx(ctx), service)
+	return c.APIClient.WaitForService(ctx, service)
+}
+
 func (c *client) Volumes(
 	ctx types.Context,
 	attachments bool) (types.ServiceVolumeMap, error) {
@@ -80,6 +102,71 @@ func (c *client) Volumes(
 	return c.APIClient.Volumes(ctx, attachments)
 }
 
+func (c *client) VolumesStream(
+	ctx types.Context,
+	attachments bool) (<-chan *types.VolumeWithService, <-chan error) {
+
+	ctx = c.requireCtx(ctx)
+
+	ctxA, err := c.withAllLocalDevices(ctx)
+	if err != nil {
+		errs := make(chan error, 1)
+		errs <- err
+		close(errs)
+		vols := make(chan *types.VolumeWithService)
+		close(vols)
+		return vols, errs
+	}
+	ctx = c.withAllInstanceIDs(ctxA)
+
+	return c.APIClient.VolumesStream(ctx, attachments)
+}
+
+func (c *client) VolumesChanged(
+	ctx types.Context,
+	sinceETag string) (bool, string, error) {
+
+	ctx = c.requireCtx(ctx)
+
+	ctxA, err := c.withAllLocalDevices(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	ctx = c.withAllInstanceIDs(ctxA)
+
+	return c.APIClient.VolumesChanged(ctx, sinceETag)
+}
+
+func (c *client) SortedVolumes(
+	ctx types.Context,
+	by string) ([]*types.VolumeWithService, error) {
+
+	ctx = c.requireCtx(ctx)
+
+	ctxA, err := c.withAllLocalDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = c.withAllInstanceIDs(ctxA)
+
+	return c.APIClient.SortedVolumes(ctx, by)
+}
+
+func (c *client) ResolveVolume(
+	ctx types.Context,
+	name string) (string, string, error) {
+
+	ctx = c.requireCtx(ctx)
+
+	ctxA, err := c.withAllLocalDevices(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	ctx = c.withAllInstanceIDs(ctxA)
+
+	return c.APIClient.ResolveVolume(ctx, name)
+}
+
 func (c *client) VolumesByService(
 	ctx types.Context,
 	service string,
@@ -110,6 +197,34 @@ func (c *client) VolumeInspect(
 	return c.APIClient.VolumeInspect(ctx, service, volumeID, attachments)
 }
 
+func (c *client) VolumeStateCounts(
+	ctx types.Context,
+	service string) (map[types.VolumeState]int, error) {
+
+	ctx = c.withInstanceID(c.requireCtx(ctx), service)
+	ctxA, err := c.withAllLocalDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = ctxA
+
+	return c.APIClient.VolumeStateCounts(ctx, service)
+}
+
+func (c *client) VolumeAttachedTo(
+	ctx types.Context,
+	service, volumeID string) ([]string, error) {
+
+	ctx = c.withInstanceID(c.requireCtx(ctx), service)
+	ctxA, err := c.withAllLocalDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = ctxA
+
+	return c.APIClient.VolumeAttachedTo(ctx, service, volumeID)
+}
+
 func (c *client) VolumeCreate(
 	ctx types.Context,
 	service string,
@@ -146,6 +261,31 @@ func (c *client) VolumeCreate(
 	return vol, nil
 }
 
+// VolumesCreateChan creates the volumes with this client's VolumeCreate so
+// that the client driver's hooks are invoked for each volume.
+func (c *client) VolumesCreateChan(
+	ctx types.Context,
+	service string,
+	requests []*types.VolumeCreateRequest) <-chan *types.VolumeCreateResult {
+
+	return utils.VolumesCreateChan(c.requireCtx(ctx), c, service, requests,
+		c.config.GetInt(types.ConfigClientBulkConcurrency))
+}
+
+// Reconcile uses this client's functions so that the client driver's hooks
+// are invoked for each change.
+func (c *client) Reconcile(
+	ctx types.Context,
+	service string,
+	desired []*types.VolumeCreateRequest,
+	opts *types.ReconcileOpts) (*types.ReconcileResult, error) {
+
+	if c.config.GetBool(types.ConfigClientNameCaseFold) {
+		desired = utils.FoldVolumeNames(desired)
+	}
+	return utils.Reconcile(c.requireCtx(ctx), c, service, desired, opts)
+}
+
 func (c *client) VolumeCreateFromSnapshot(
 	ctx types.Context,
 	service, snapshotID string,
@@ -330,6 +470,25 @@ func (c *client) VolumeSnapshot(
 	return c.APIClient.VolumeSnapshot(ctx, service, volumeID, request)
 }
 
+func (c *client) VolumeExport(
+	ctx types.Context,
+	service, volumeID string,
+	w io.Writer) error {
+
+	ctx = c.withInstanceID(c.requireCtx(ctx), service)
+	return c.APIClient.VolumeExport(ctx, service, volumeID, w)
+}
+
+func (c *client) VolumeImport(
+	ctx types.Context,
+	service, volumeID string,
+	r io.Reader,
+	size int64) error {
+
+	ctx = c.withInstanceID(c.requireCtx(ctx), service)
+	return c.APIClient.VolumeImport(ctx, service, volumeID, r, size)
+}
+
 func (c *client) Snapshots(
 	ctx types.Context) (types.ServiceSnapshotMap, error) {
 
@@ -24,9 +24,21 @@ func (c *client) InstanceID(
 			c.clientType, "InstanceID")
 	}
 
+	if err := c.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+	return c.instanceID(ctx, opts)
+}
+
+// instanceID gets the instance ID without first ensuring the server is
+// dialed, since it is invoked while dialing.
+func (c *client) instanceID(
+	ctx types.Context,
+	opts types.Store) (*types.InstanceID, error) {
+
 	ctx = context.RequireTX(ctx.Join(c.ctx))
 
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := c.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
@@ -77,9 +89,13 @@ func (c *client) NextDevice(
 			c.clientType, "NextDevice")
 	}
 
+	if err := c.ensureDialed(ctx); err != nil {
+		return "", err
+	}
+
 	ctx = context.RequireTX(ctx.Join(c.ctx))
 
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := c.serviceName(ctx)
 	if !ok {
 		return "", goof.New("missing service name")
 	}
@@ -108,9 +124,13 @@ func (c *client) LocalDevices(
 			c.clientType, "LocalDevices")
 	}
 
+	if err := c.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	ctx = context.RequireTX(ctx.Join(c.ctx))
 
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := c.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
@@ -145,9 +165,13 @@ func (c *client) WaitForDevice(
 			c.clientType, "WaitForDevice")
 	}
 
+	if err := c.ensureDialed(ctx); err != nil {
+		return false, nil, err
+	}
+
 	ctx = context.RequireTX(ctx.Join(c.ctx))
 
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := c.serviceName(ctx)
 	if !ok {
 		return false, nil, goof.New("missing service name")
 	}
@@ -2,13 +2,14 @@ package libstorage
 
 import (
 	"crypto/tls"
+	"fmt"
 	"net"
 	"net/http"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/gofig"
-	"github.com/akutz/gotil"
+	gocontext "golang.org/x/net/context"
 
 	apiclient "github.com/emccode/libstorage/api/client"
 	"github.com/emccode/libstorage/api/context"
@@ -43,42 +44,82 @@ func (d *driver) Init(ctx types.Context, config gofig.Config) error {
 	d.ctx = ctx.WithValue(context.HostKey, addr)
 	d.ctx.Debug("got configured host address")
 
-	proto, lAddr, err := gotil.ParseAddress(addr)
+	proto, lAddr, err := utils.ParseHostAddress(addr)
 	if err != nil {
 		return err
 	}
 
+	// a missing socket is reported before anything else is configured unless
+	// the server is not expected to be running until the first operation
+	lazyDial := config.GetBool(types.ConfigClientLazyDial)
+	if !lazyDial {
+		if err := utils.CheckSocket(proto, lAddr); err != nil {
+			return err
+		}
+	}
+
 	tlsConfig, err := utils.ParseTLSConfig(
 		config, logFields, "libstorage.client")
 	if err != nil {
 		return err
 	}
+	if tlsConfig != nil {
+		tlsConfig.ClientSessionCache = utils.NewClientSessionCache(config)
+	}
 
 	host := getHost(proto, lAddr, tlsConfig)
 	lsxPath := config.GetString(types.ConfigExecutorPath)
 	cliType := types.ParseClientType(config.GetString(types.ConfigClientType))
 	disableKeepAlive := config.GetBool(types.ConfigHTTPDisableKeepAlive)
+	tlsFallbackPlain := config.GetBool(types.ConfigClientTLSFallbackPlain)
 
 	logFields["host"] = host
 	logFields["lsxPath"] = lsxPath
 	logFields["clientType"] = cliType
 	logFields["disableKeepAlive"] = disableKeepAlive
+	logFields["tlsFallbackPlain"] = tlsFallbackPlain
 
-	httpTransport := &http.Transport{
-		Dial: func(string, string) (net.Conn, error) {
-			if tlsConfig == nil {
-				return net.Dial(proto, lAddr)
-			}
-			return tls.Dial(proto, lAddr, tlsConfig)
-		},
-		DisableKeepAlives: disableKeepAlive,
+	dialer := utils.NewDialer(config)
+	logFields["keepAlive"] = dialer.KeepAlive
+
+	dialLimiter := utils.NewDialLimiter(config)
+	logFields["maxConcurrentDials"] = dialLimiter.Limit()
+	logFields["backpressureMode"] = config.GetString(
+		types.ConfigClientHTTPBackpressureMode)
+
+	hostAddr := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		hostAddr = net.JoinHostPort(host, "80")
 	}
 
-	apiClient := apiclient.New(host, httpTransport)
+	httpTransport := utils.NewTransport(config, newDialFunc(proto, lAddr,
+		hostAddr, dialer, dialLimiter, tlsConfig, tlsFallbackPlain))
+	logFields["maxIdleConnsPerHost"] = httpTransport.MaxIdleConnsPerHost
+	logFields["idleConnTimeout"] = httpTransport.IdleConnTimeout
+
+	// a round tripper in the context is used instead of a transport that
+	// dials the configured host, ex. by tests that do not listen on a socket
+	// or to route requests through a proxy
+	var apiClient types.APIClient
+	if rt, ok := context.RoundTripper(ctx); ok && rt != nil {
+		apiClient = apiclient.New(host, nil, config)
+		apiClient.RoundTripper(rt)
+		logFields["roundTripper"] = fmt.Sprintf("%T", rt)
+	} else {
+		apiClient = apiclient.New(host, httpTransport, config)
+		apiClient.ServiceTransport(
+			func(service string) (*http.Transport, error) {
+				return newServiceTransport(config, service, proto, lAddr,
+					hostAddr, dialer, dialLimiter, tlsFallbackPlain)
+			})
+	}
 	logReq := config.GetBool(types.ConfigLogHTTPRequests)
 	logRes := config.GetBool(types.ConfigLogHTTPResponses)
 	apiClient.LogRequests(logReq)
 	apiClient.LogResponses(logRes)
+	if observer, ok := context.Observer(ctx); ok && observer != nil {
+		apiClient.Observer(observer)
+	}
 
 	logFields["enableInstanceIDHeaders"] = EnableInstanceIDHeaders
 	logFields["enableLocalDevicesHeaders"] = EnableLocalDevicesHeaders
@@ -91,6 +132,7 @@ func (d *driver) Init(ctx types.Context, config gofig.Config) error {
 		config:       config,
 		clientType:   cliType,
 		serviceCache: &lss{Store: utils.NewStore()},
+		lazyDial:     lazyDial,
 	}
 
 	if d.clientType == types.IntegrationClient {
@@ -109,8 +151,13 @@ func (d *driver) Init(ctx types.Context, config gofig.Config) error {
 		d.instanceIDCache = &lss{Store: newIIDCache()}
 	}
 
+	logFields["lazyDial"] = d.lazyDial
 	d.ctx.WithFields(logFields).Info("created libStorage client")
 
+	if d.lazyDial {
+		return nil
+	}
+
 	if err := d.dial(ctx); err != nil {
 		return err
 	}
@@ -118,3 +165,71 @@ func (d *driver) Init(ctx types.Context, config gofig.Config) error {
 	d.ctx.Info("successefully dialed libStorage server")
 	return nil
 }
+
+// newDialFunc returns the function with which a transport dials the server.
+// The dial is abandoned if the request's context is done so that cancelled
+// requests do not leave dials in progress. The transport pools the
+// connections by host, so requests to a unix socket share the connections
+// established for the synthetic host name.
+func newDialFunc(
+	proto, lAddr, hostAddr string,
+	dialer *net.Dialer,
+	dialLimiter *utils.DialLimiter,
+	tlsConfig *tls.Config,
+	tlsFallbackPlain bool) func(
+	gocontext.Context, string, string) (net.Conn, error) {
+
+	return func(
+		dialCtx gocontext.Context,
+		network, addr string) (net.Conn, error) {
+
+		dialProto, dialAddr := proto, lAddr
+		// a leader redirect sends requests to a server other than the
+		// configured one
+		if addr != hostAddr {
+			dialProto, dialAddr = network, addr
+		}
+		if err := utils.CheckSocket(dialProto, dialAddr); err != nil {
+			return nil, err
+		}
+		return dialLimiter.Dial(dialCtx, func() (net.Conn, error) {
+			if tlsConfig == nil {
+				return dialer.DialContext(dialCtx, dialProto, dialAddr)
+			}
+			return utils.DialTLS(context.New(dialCtx), dialer,
+				dialProto, dialAddr, tlsConfig, tlsFallbackPlain)
+		})
+	}
+}
+
+// newServiceTransport returns a transport that presents the client
+// certificate configured for the provided service, or nil if the service
+// does not override the client certificate.
+func newServiceTransport(
+	config gofig.Config,
+	service, proto, lAddr, hostAddr string,
+	dialer *net.Dialer,
+	dialLimiter *utils.DialLimiter,
+	tlsFallbackPlain bool) (*http.Transport, error) {
+
+	root := fmt.Sprintf("%s.%s", types.ConfigClient, service)
+	certFileKey := fmt.Sprintf("%s.tls.certFile", root)
+	keyFileKey := fmt.Sprintf("%s.tls.keyFile", root)
+	if !config.IsSet(certFileKey) && !config.IsSet(keyFileKey) {
+		return nil, nil
+	}
+
+	tlsConfig, err := utils.ParseTLSConfig(
+		config, nil, root, types.ConfigClient)
+	if err != nil {
+		return nil, err
+	}
+	// a tlsConfig is nil if TLS is disabled, in which case the service's
+	// requests are sent without TLS like all others
+	if tlsConfig != nil {
+		tlsConfig.ClientSessionCache = utils.NewClientSessionCache(config)
+	}
+
+	return utils.NewTransport(config, newDialFunc(proto, lAddr, hostAddr,
+		dialer, dialLimiter, tlsConfig, tlsFallbackPlain)), nil
+}
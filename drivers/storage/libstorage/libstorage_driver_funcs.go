@@ -2,7 +2,6 @@ package libstorage
 
 import (
 	"github.com/akutz/goof"
-	"github.com/emccode/libstorage/api/context"
 	"github.com/emccode/libstorage/api/types"
 	"github.com/emccode/libstorage/api/utils"
 )
@@ -22,11 +21,15 @@ func (d *driver) XCLI() types.StorageExecutorCLI {
 func (d *driver) NextDeviceInfo(
 	ctx types.Context) (*types.NextDeviceInfo, error) {
 
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	si, err := d.getServiceInfo(serviceName)
 	if err != nil {
 		return nil, err
@@ -36,11 +39,15 @@ func (d *driver) NextDeviceInfo(
 
 func (d *driver) Type(ctx types.Context) (types.StorageType, error) {
 
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return "", goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return "", err
+	}
+
 	si, err := d.getServiceInfo(serviceName)
 	if err != nil {
 		return "", err
@@ -57,11 +64,15 @@ func (d *driver) InstanceInspect(
 			d.clientType, "InstanceInspect")
 	}
 
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	return d.client.InstanceInspect(ctx, serviceName)
 }
 
@@ -70,11 +81,15 @@ func (d *driver) Volumes(
 	opts *types.VolumesOpts) ([]*types.Volume, error) {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	objMap, err := d.client.VolumesByService(ctx, serviceName, opts.Attachments)
 	if err != nil {
 		return nil, err
@@ -94,11 +109,15 @@ func (d *driver) VolumeInspect(
 	opts *types.VolumeInspectOpts) (*types.Volume, error) {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	return d.client.VolumeInspect(ctx, serviceName, volumeID, opts.Attachments)
 }
 
@@ -108,11 +127,15 @@ func (d *driver) VolumeCreate(
 	opts *types.VolumeCreateOpts) (*types.Volume, error) {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	req := &types.VolumeCreateRequest{
 		Name:             name,
 		AvailabilityZone: opts.AvailabilityZone,
@@ -131,11 +154,15 @@ func (d *driver) VolumeCreateFromSnapshot(
 	opts *types.VolumeCreateOpts) (*types.Volume, error) {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	req := &types.VolumeCreateRequest{
 		Name:             volumeName,
 		AvailabilityZone: opts.AvailabilityZone,
@@ -154,11 +181,15 @@ func (d *driver) VolumeCopy(
 	opts types.Store) (*types.Volume, error) {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	req := &types.VolumeCopyRequest{
 		VolumeName: volumeName,
 		Opts:       opts.Map(),
@@ -173,11 +204,15 @@ func (d *driver) VolumeSnapshot(
 	opts types.Store) (*types.Snapshot, error) {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	req := &types.VolumeSnapshotRequest{
 		SnapshotName: snapshotName,
 		Opts:         opts.Map(),
@@ -192,11 +227,15 @@ func (d *driver) VolumeRemove(
 	opts types.Store) error {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return err
+	}
+
 	return d.client.VolumeRemove(ctx, serviceName, volumeID)
 }
 
@@ -211,11 +250,15 @@ func (d *driver) VolumeAttach(
 	}
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, "", goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, "", err
+	}
+
 	req := &types.VolumeAttachRequest{
 		NextDeviceName: opts.NextDevice,
 		Force:          opts.Force,
@@ -236,11 +279,15 @@ func (d *driver) VolumeDetach(
 	}
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	req := &types.VolumeDetachRequest{
 		Force: opts.Force,
 		Opts:  opts.Opts.Map(),
@@ -254,11 +301,15 @@ func (d *driver) Snapshots(
 	opts types.Store) ([]*types.Snapshot, error) {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	objMap, err := d.client.SnapshotsByService(ctx, serviceName)
 
 	if err != nil {
@@ -279,11 +330,15 @@ func (d *driver) SnapshotInspect(
 	opts types.Store) (*types.Snapshot, error) {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	return d.client.SnapshotInspect(ctx, serviceName, snapshotID)
 }
 
@@ -293,11 +348,15 @@ func (d *driver) SnapshotCopy(
 	opts types.Store) (*types.Snapshot, error) {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return nil, goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return nil, err
+	}
+
 	req := &types.SnapshotCopyRequest{
 		SnapshotName:  snapshotName,
 		DestinationID: destinationID,
@@ -313,11 +372,15 @@ func (d *driver) SnapshotRemove(
 	opts types.Store) error {
 
 	ctx = d.requireCtx(ctx)
-	serviceName, ok := context.ServiceName(ctx)
+	serviceName, ok := d.serviceName(ctx)
 	if !ok {
 		return goof.New("missing service name")
 	}
 
+	if err := d.ensureDialed(ctx); err != nil {
+		return err
+	}
+
 	return d.client.SnapshotRemove(ctx, serviceName, snapshotID)
 }
 
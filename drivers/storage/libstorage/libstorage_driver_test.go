@@ -0,0 +1,622 @@
+package libstorage
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+	gocontext "golang.org/x/net/context"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+	_ "github.com/emccode/libstorage/imports/config"
+)
+
+// newDeadHostConfig returns a configuration whose host does not accept
+// connections.
+func newDeadHostConfig(t *testing.T) gofig.Config {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	config := gofig.New()
+	config.Set(types.ConfigHost, "tcp://"+addr)
+	config.Set(types.ConfigClientType, "integration")
+	return config
+}
+
+func TestInitDialsServer(t *testing.T) {
+	d := newDriver()
+	err := d.Init(context.Background(), newDeadHostConfig(t))
+	assert.Error(t, err)
+}
+
+func TestInitLazyDial(t *testing.T) {
+	config := newDeadHostConfig(t)
+	config.Set(types.ConfigClientLazyDial, true)
+
+	d := newDriver()
+	if !assert.NoError(t, d.Init(context.Background(), config)) {
+		t.FailNow()
+	}
+
+	ctx := context.Background().WithValue(context.ServiceKey, "vfs")
+	_, err := d.Volumes(ctx, &types.VolumesOpts{})
+	assert.Error(t, err)
+	assert.False(t, d.(*driver).dialed)
+}
+
+func TestUnixSocketConnReuse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libstorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sock := path.Join(dir, "libstorage.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var conns int32
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+		}),
+		ConnState: func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt32(&conns, 1)
+			}
+		},
+	}
+	go server.Serve(l)
+	defer server.Close()
+
+	config := gofig.New()
+	config.Set(types.ConfigHost, "unix://"+sock)
+	config.Set(types.ConfigClientType, "integration")
+	config.Set(types.ConfigClientLazyDial, true)
+
+	d := newDriver()
+	if !assert.NoError(t, d.Init(context.Background(), config)) {
+		t.FailNow()
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		_, err := d.(*driver).APIClient.Volumes(ctx, false)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestInitRoundTripper(t *testing.T) {
+	var paths []string
+	rt := roundTripperFunc(
+		func(req *http.Request) (*http.Response, error) {
+			paths = append(paths, req.URL.Path)
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body: ioutil.NopCloser(strings.NewReader(
+					`{"vfs":{"vfs-000":{"id":"vfs-000","name":"v0"}}}`)),
+				Request: req,
+			}, nil
+		})
+
+	// the configured host does not accept connections, so the requests must
+	// be sent with the round tripper
+	config := newDeadHostConfig(t)
+	config.Set(types.ConfigClientLazyDial, true)
+
+	d := newDriver()
+	ctx := context.WithRoundTripper(context.Background(), rt)
+	if !assert.NoError(t, d.Init(ctx, config)) {
+		t.FailNow()
+	}
+
+	vols, err := d.(*driver).APIClient.Volumes(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v0", vols["vfs"]["vfs-000"].Name)
+	assert.Equal(t, []string{"/volumes"}, paths)
+}
+
+func TestInitObserver(t *testing.T) {
+	rt := roundTripperFunc(
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+				Request:    req,
+			}, nil
+		})
+
+	var (
+		observedL sync.Mutex
+		observed  []string
+	)
+	observer := types.ObserverFunc(func(
+		method, path string, statusCode int,
+		duration time.Duration, err error) {
+
+		observedL.Lock()
+		defer observedL.Unlock()
+		observed = append(observed, method+" "+path)
+	})
+
+	config := newDeadHostConfig(t)
+	config.Set(types.ConfigClientLazyDial, true)
+
+	d := newDriver()
+	ctx := context.WithObserver(
+		context.WithRoundTripper(context.Background(), rt), observer)
+	if !assert.NoError(t, d.Init(ctx, config)) {
+		t.FailNow()
+	}
+
+	// setting the observer while requests are in-flight must not race
+	apiClient := d.(*driver).APIClient
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			apiClient.Volumes(context.Background(), false)
+		}()
+	}
+	apiClient.Observer(observer)
+	wg.Wait()
+
+	assert.Len(t, observed, 4)
+	assert.Equal(t, "GET /volumes?attachments=false", observed[0])
+}
+
+func TestServiceAliasInstanceID(t *testing.T) {
+	var (
+		paths []string
+		iids  []string
+	)
+	rt := roundTripperFunc(
+		func(req *http.Request) (*http.Response, error) {
+			paths = append(paths, req.URL.Path)
+			iids = append(iids, req.Header.Get(types.InstanceIDHeader))
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body: ioutil.NopCloser(
+					strings.NewReader(`{"id":"vfs-000"}`)),
+				Request: req,
+			}, nil
+		})
+
+	config := newDeadHostConfig(t)
+	config.Set(types.ConfigClientLazyDial, true)
+	config.Set(types.ConfigClientServiceAliases, map[string]interface{}{
+		"default": "vfs",
+	})
+
+	d := newDriver()
+	ctx := context.WithRoundTripper(context.Background(), rt)
+	if !assert.NoError(t, d.Init(ctx, config)) {
+		t.FailNow()
+	}
+	c := &d.(*driver).client
+	c.instanceIDCache.Set(
+		"vfs", &types.InstanceID{ID: "iid-000", Driver: "vfs"})
+
+	// the instance ID is looked up by the concrete name of the service
+	_, err := c.VolumeInspect(context.Background(), "default", "vfs-000", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/volumes/vfs/vfs-000"}, paths)
+	if assert.Len(t, iids, 1) {
+		assert.Contains(t, iids[0], "iid-000")
+	}
+}
+
+func TestVolumeDetachAllSerialized(t *testing.T) {
+	var (
+		requestsL sync.Mutex
+		requests  []string
+	)
+	attaching := make(chan struct{})
+	release := make(chan struct{})
+
+	rt := roundTripperFunc(
+		func(req *http.Request) (*http.Response, error) {
+			body := `{"id":"vfs-000"}`
+			if req.Method == http.MethodPost {
+				requestsL.Lock()
+				requests = append(requests, req.URL.RequestURI())
+				requestsL.Unlock()
+				switch req.URL.RequestURI() {
+				case "/volumes/vfs/vfs-000?attach":
+					close(attaching)
+					<-release
+					body = `{"volume":{"id":"vfs-000"},"attachToken":"t0"}`
+				default:
+					body = `{}`
+				}
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		})
+
+	config := newDeadHostConfig(t)
+	config.Set(types.ConfigClientLazyDial, true)
+
+	d := newDriver()
+	ctx := context.WithRoundTripper(context.Background(), rt)
+	if !assert.NoError(t, d.Init(ctx, config)) {
+		t.FailNow()
+	}
+	c := &d.(*driver).client
+	c.instanceIDCache.Set(
+		"vfs", &types.InstanceID{ID: "iid-000", Driver: "vfs"})
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _, err := c.VolumeAttach(
+			ctx, "vfs", "vfs-000", &types.VolumeAttachRequest{})
+		assert.NoError(t, err)
+	}()
+	<-attaching
+	go func() {
+		defer wg.Done()
+		_, err := c.VolumeDetachAll(ctx, &types.VolumeDetachRequest{})
+		assert.NoError(t, err)
+	}()
+
+	// the detach waits for the attach to the same instance to complete
+	time.Sleep(50 * time.Millisecond)
+	requestsL.Lock()
+	assert.Equal(t, []string{"/volumes/vfs/vfs-000?attach"}, requests)
+	requestsL.Unlock()
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []string{
+		"/volumes/vfs/vfs-000?attach", "/volumes?detach"}, requests)
+}
+
+func TestInitSocketNotFound(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libstorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := gofig.New()
+	config.Set(types.ConfigHost, "unix://"+path.Join(dir, "libstorage.sock"))
+	config.Set(types.ConfigClientType, "integration")
+
+	d := newDriver()
+	err = d.Init(context.Background(), config)
+	var serr *types.ErrSocketNotFound
+	assert.True(t, errors.As(err, &serr), "%v", err)
+}
+
+func TestInitUnsupportedProtocol(t *testing.T) {
+	config := gofig.New()
+	config.Set(types.ConfigHost, "http://127.0.0.1:7979")
+	config.Set(types.ConfigClientType, "integration")
+	config.Set(types.ConfigClientLazyDial, true)
+
+	d := newDriver()
+	err := d.Init(context.Background(), config)
+	assert.IsType(t, &types.ErrUnsupportedProtocol{}, err)
+}
+
+// writeTestCert writes a self-signed certificate for the DNS name, and its
+// key, to the directory and returns the paths of the files.
+func writeTestCert(
+	t *testing.T, dir, dnsName string) (certFile, keyFile string) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: dnsName},
+		DNSNames:              []string{dnsName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageDigitalSignature |
+			x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = path.Join(dir, dnsName+".crt")
+	keyFile = path.Join(dir, dnsName+".key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(
+		&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(
+		&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSInfoServerName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libstorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const serverName = "libstorage.example.com"
+	certFile, keyFile := writeTestCert(t, dir, serverName)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+		}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	// the client's host is the server name, which has no port and does not
+	// resolve, so the transport must dial the configured address
+	config := gofig.New()
+	config.Set(types.ConfigHost, "tcp://"+server.Listener.Addr().String())
+	config.Set(types.ConfigClientType, "integration")
+	config.Set(types.ConfigClientLazyDial, true)
+	config.Set("libstorage.client.tls.certFile", certFile)
+	config.Set("libstorage.client.tls.keyFile", keyFile)
+	config.Set("libstorage.client.tls.trustedCertsFile", certFile)
+	config.Set("libstorage.client.tls.serverName", serverName)
+
+	d := newDriver()
+	if !assert.NoError(t, d.Init(context.Background(), config)) {
+		t.FailNow()
+	}
+
+	info, err := d.(*driver).APIClient.TLSInfo()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, serverName, info.ServerName)
+	if assert.Len(t, info.PeerCertificates, 1) {
+		assert.Equal(t, []string{serverName}, info.PeerCertificates[0].DNSNames)
+	}
+
+	_, err = d.(*driver).APIClient.Volumes(context.Background(), false)
+	assert.NoError(t, err)
+}
+
+func TestServiceClientCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libstorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const serverName = "libstorage.example.com"
+	certFile, keyFile := writeTestCert(t, dir, serverName)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		lock     sync.Mutex
+		subjects = map[string]string{}
+	)
+	newServer := func(
+		name string, h http.HandlerFunc) *httptest.Server {
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				lock.Lock()
+				subjects[name+" "+r.Method+" "+r.URL.Path] =
+					r.TLS.PeerCertificates[0].Subject.CommonName
+				lock.Unlock()
+				h(w, r)
+			}))
+		server.TLS = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   tls.RequireAnyClientCert,
+		}
+		server.StartTLS()
+		return server
+	}
+
+	leader := newServer("leader", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"vfs-000"}`))
+	})
+	defer leader.Close()
+
+	// a volume created for service b is redirected to the leader
+	server := newServer("server", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Location", "https://"+
+				leader.Listener.Addr().String()+r.URL.RequestURI())
+			w.WriteHeader(http.StatusTemporaryRedirect)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	})
+	defer server.Close()
+
+	config := gofig.New()
+	config.Set(types.ConfigHost, "tcp://"+server.Listener.Addr().String())
+	config.Set(types.ConfigClientLazyDial, true)
+	config.Set(types.ConfigClientHTTPFollowLeaderRedirects, true)
+	config.Set("libstorage.client.tls.trustedCertsFile", certFile)
+	config.Set("libstorage.client.tls.serverName", serverName)
+	for _, name := range []string{"global", "a", "b"} {
+		root := "libstorage.client."
+		if name != "global" {
+			root += name + "."
+		}
+		certFile, keyFile := writeTestCert(t, dir, name)
+		config.Set(root+"tls.certFile", certFile)
+		config.Set(root+"tls.keyFile", keyFile)
+	}
+
+	d := newDriver()
+	if !assert.NoError(t, d.Init(context.Background(), config)) {
+		t.FailNow()
+	}
+	client := d.(*driver).APIClient
+	ctx := context.Background()
+
+	_, err = client.Volumes(ctx, false)
+	assert.NoError(t, err)
+	_, err = client.VolumesByService(ctx, "a", false)
+	assert.NoError(t, err)
+	_, err = client.VolumesByService(ctx, "b", false)
+	assert.NoError(t, err)
+	vol, err := client.VolumeCreate(
+		ctx, "b", &types.VolumeCreateRequest{Name: "v0"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "vfs-000", vol.ID)
+	}
+
+	assert.Equal(t, map[string]string{
+		"server GET /volumes":    "global",
+		"server GET /volumes/a":  "a",
+		"server GET /volumes/b":  "b",
+		"server POST /volumes/b": "b",
+		"leader POST /volumes/b": "b",
+	}, subjects)
+}
+
+func TestServiceTransportTLSDisabled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libstorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCert(t, dir, "a")
+	config := gofig.New()
+	config.Set("libstorage.client.a.tls.certFile", certFile)
+	config.Set("libstorage.client.a.tls.keyFile", keyFile)
+	config.Set("libstorage.client.a.tls.disabled", true)
+
+	transport, err := newServiceTransport(config, "a", "tcp",
+		"127.0.0.1:7979", "127.0.0.1:7979", utils.NewDialer(config),
+		utils.NewDialLimiter(config), false)
+	assert.NoError(t, err)
+	assert.NotNil(t, transport)
+}
+
+func TestRetriedErrorTypes(t *testing.T) {
+
+	var attempts int32
+	goCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&attempts, 1)
+			switch r.URL.Path {
+			case "/volumes/vfs/vfs-000":
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case "/volumes/vfs/vfs-001":
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+			default:
+				if n == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				cancel()
+				<-r.Context().Done()
+			}
+		}))
+	defer server.Close()
+
+	// the registered defaults retry a failed request three times
+	config := gofig.New()
+	config.Set(types.ConfigHost, "tcp://"+server.Listener.Addr().String())
+	config.Set(types.ConfigClientLazyDial, true)
+	config.Set(types.ConfigClientHTTPRetryBackoff, "1ms")
+
+	d := newDriver()
+	if !assert.NoError(t, d.Init(context.Background(), config)) {
+		t.FailNow()
+	}
+	client := d.(*driver).APIClient
+	ctx := context.Background()
+
+	_, err := client.VolumeInspect(ctx, "vfs", "vfs-000", false)
+	if herr, ok := err.(*types.HTTPError); assert.True(t, ok, "%T", err) {
+		assert.Equal(t, http.StatusServiceUnavailable, herr.Status())
+		assert.Len(t, herr.Attempts(), 4)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	_, err = client.VolumeInspect(ctx, "vfs", "vfs-001", false)
+	if terr, ok := err.(types.TransportError); assert.True(t, ok, "%T", err) {
+		assert.True(t, terr.Temporary())
+		assert.Len(t, err.(types.RetriedError).Attempts(), 4)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	_, err = client.VolumeInspect(context.New(goCtx), "vfs", "vfs-002", false)
+	if cerr, ok := err.(*types.ErrCanceled); assert.True(t, ok, "%T", err) {
+		assert.Equal(t, types.CancelReasonCaller, cerr.Reason)
+		assert.Len(t, cerr.Attempts(), 2)
+	}
+}
@@ -43,9 +43,23 @@ func (c *client) withAllInstanceIDs(ctx types.Context) types.Context {
 	return ctx.WithValue(context.AllInstanceIDsKey, iidm)
 }
 
+// serviceName returns the concrete name of the context's service, resolving
+// the name if it is an alias.
+func (c *client) serviceName(ctx types.Context) (string, bool) {
+	service, ok := context.ServiceName(ctx)
+	if !ok {
+		return "", false
+	}
+	return c.ServiceName(service), true
+}
+
+// withInstanceID returns a copy of the context with the concrete name of the
+// service, resolved if the name is an alias, and the instance ID for that
+// service, if any.
 func (c *client) withInstanceID(
 	ctx types.Context, service string) types.Context {
 
+	service = c.ServiceName(service)
 	ctx = ctx.WithValue(context.ServiceKey, service)
 
 	if c.isController() {
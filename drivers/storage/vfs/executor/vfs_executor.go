@@ -2,9 +2,13 @@ package executor
 
 import (
 	"bufio"
+	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
@@ -27,6 +31,7 @@ type driver struct {
 	config      gofig.Config
 	rootDir     string
 	devFilePath string
+	devMatcher  *deviceMatcher
 }
 
 func init() {
@@ -46,6 +51,7 @@ func (d *driver) Init(ctx types.Context, config gofig.Config) error {
 
 	d.rootDir = vfs.RootDir(config)
 	d.devFilePath = vfs.DeviceFilePath(config)
+	d.devMatcher = newDeviceMatcher(vfs.DevicePrefixes(config))
 	if !gotil.FileExists(d.devFilePath) {
 		err := ioutil.WriteFile(d.devFilePath, initialDeviceFile, 0644)
 		if err != nil {
@@ -79,10 +85,6 @@ func (d *driver) InstanceID(
 	return iid, nil
 }
 
-var (
-	avaiDevRX = regexp.MustCompile(`^(/dev/xvd[a-z])$`)
-)
-
 // NextDevice returns the next available device.
 func (d *driver) NextDevice(
 	ctx types.Context,
@@ -108,26 +110,20 @@ func (d *driver) NextDevice(
 	defer f.Close()
 
 	scn := bufio.NewScanner(f)
-	for {
-		if !scn.Scan() {
-			break
-		}
-
-		m := avaiDevRX.FindSubmatch(scn.Bytes())
-		if len(m) == 0 {
+	for scn.Scan() {
+		prefix, dev, mountPoint := d.devMatcher.match(scn.Text())
+		if prefix == "" || mountPoint != "" {
 			continue
 		}
-
-		return string(m[1]), nil
+		return dev, nil
+	}
+	if err := scn.Err(); err != nil {
+		return "", err
 	}
 
 	return "", goof.New("no available devices")
 }
 
-var (
-	devRX = regexp.MustCompile(`^(/dev/xvd[a-z])(?:=(.+))?$`)
-)
-
 // LocalDevices returns a map of the system's local devices.
 func (d *driver) LocalDevices(
 	ctx types.Context,
@@ -148,6 +144,12 @@ func (d *driver) LocalDevices(
 	defer devFileRWL.Unlock()
 
 	if !gotil.FileExists(d.devFilePath) {
+		if vfs.LocalDevicesMissingOK(d.config) {
+			return &types.LocalDevices{
+				Driver:    vfs.Name,
+				DeviceMap: map[string]string{},
+			}, nil
+		}
 		return nil, goof.New("device file missing")
 	}
 
@@ -157,29 +159,95 @@ func (d *driver) LocalDevices(
 	}
 	defer f.Close()
 
-	localDevs := map[string]string{}
+	devsByPrefix, err := d.devMatcher.localDevices(f)
+	if err != nil {
+		return nil, err
+	}
 
-	scn := bufio.NewScanner(f)
-	for {
-		if !scn.Scan() {
-			break
+	localDevs := map[string]string{}
+	for _, devs := range devsByPrefix {
+		for dev, mountPoint := range devs {
+			localDevs[dev] = mountPoint
 		}
+	}
 
-		m := devRX.FindSubmatch(scn.Bytes())
-		if len(m) == 0 {
+	return &types.LocalDevices{Driver: vfs.Name, DeviceMap: localDevs}, nil
+}
+
+// deviceMatcher matches the lines of the devices file that name a device
+// whose path begins with one of a set of prefixes. A line is the path of a
+// device, optionally followed by = and the path at which it is mounted.
+type deviceMatcher struct {
+	prefixes []string
+}
+
+var devNameSuffixRX = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// newDeviceMatcher returns a deviceMatcher for the prefixes. A prefix that is
+// a device name rather than a path, such as nvme, is a prefix of the paths of
+// the devices in /dev. A device is matched by the longest of the prefixes
+// that match it.
+func newDeviceMatcher(prefixes []string) *deviceMatcher {
+	m := &deviceMatcher{}
+	for _, p := range prefixes {
+		p = strings.TrimSpace(p)
+		if p == "" {
 			continue
 		}
-
-		dev := m[1]
-		var mountPoint []byte
-		if len(m) > 2 {
-			mountPoint = m[2]
+		if !strings.HasPrefix(p, "/") {
+			p = path.Join("/dev", p)
 		}
+		m.prefixes = append(m.prefixes, p)
+	}
+	sort.Slice(m.prefixes, func(i, j int) bool {
+		return len(m.prefixes[i]) > len(m.prefixes[j])
+	})
+	return m
+}
 
-		localDevs[string(dev)] = string(mountPoint)
+// match returns the prefix that matches the device named by the line, the
+// device's path, and its mount point, if any. An empty prefix is returned if
+// the line is malformed or names a device that no prefix matches.
+func (m *deviceMatcher) match(line string) (prefix, dev, mountPoint string) {
+	dev = strings.TrimSpace(line)
+	if i := strings.IndexByte(dev, '='); i >= 0 {
+		dev, mountPoint = dev[:i], dev[i+1:]
+		if mountPoint == "" {
+			return "", "", ""
+		}
 	}
+	for _, p := range m.prefixes {
+		if strings.HasPrefix(dev, p) &&
+			devNameSuffixRX.MatchString(dev[len(p):]) {
+			return p, dev, mountPoint
+		}
+	}
+	return "", "", ""
+}
 
-	return &types.LocalDevices{Driver: vfs.Name, DeviceMap: localDevs}, nil
+// localDevices reads the lines of a devices file and returns the matched
+// devices, and their mount points, keyed by the prefix that matched them.
+// Lines that are malformed or name devices that no prefix matches are
+// skipped.
+func (m *deviceMatcher) localDevices(
+	r io.Reader) (map[string]map[string]string, error) {
+
+	devsByPrefix := map[string]map[string]string{}
+	scn := bufio.NewScanner(r)
+	for scn.Scan() {
+		prefix, dev, mountPoint := m.match(scn.Text())
+		if prefix == "" {
+			continue
+		}
+		if devsByPrefix[prefix] == nil {
+			devsByPrefix[prefix] = map[string]string{}
+		}
+		devsByPrefix[prefix][dev] = mountPoint
+	}
+	if err := scn.Err(); err != nil {
+		return nil, err
+	}
+	return devsByPrefix, nil
 }
 
 var initialDeviceFile = []byte(`/dev/xvda
@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/types"
+	"github.com/emccode/libstorage/api/utils"
+)
+
+const testDeviceFile = `/dev/xvda=/mnt/a
+/dev/xvdb
+  /dev/nvme0n1
+/dev/nvme1n1=/mnt/nvme1
+
+/dev/sda
+/dev/xvd
+/dev/xvd c
+=/mnt/orphan
+/dev/xvde=
+garbage
+/dev/nvme2n1=/mnt/nvme2`
+
+func TestDeviceMatcher(t *testing.T) {
+
+	m := newDeviceMatcher([]string{"/dev/xvd", "nvme", " "})
+	assert.Equal(t, []string{"/dev/nvme", "/dev/xvd"}, m.prefixes)
+
+	devs, err := m.localDevices(strings.NewReader(testDeviceFile))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"/dev/xvd": {
+			"/dev/xvda": "/mnt/a",
+			"/dev/xvdb": "",
+		},
+		"/dev/nvme": {
+			"/dev/nvme0n1": "",
+			"/dev/nvme1n1": "/mnt/nvme1",
+			"/dev/nvme2n1": "/mnt/nvme2",
+		},
+	}, devs)
+
+	// the longest matching prefix is reported
+	m = newDeviceMatcher([]string{"/dev/nvme", "/dev/nvme1"})
+	prefix, dev, mountPoint := m.match("/dev/nvme1n1=/mnt/nvme1")
+	assert.Equal(t, "/dev/nvme1", prefix)
+	assert.Equal(t, "/dev/nvme1n1", dev)
+	assert.Equal(t, "/mnt/nvme1", mountPoint)
+
+	prefix, _, _ = m.match("/dev/xvda")
+	assert.Equal(t, "", prefix)
+}
+
+func TestLocalDevicesPrefixes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(
+		path.Join(dir, "dev"), []byte(testDeviceFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := gofig.New()
+	config.Set("vfs.root", dir)
+	config.Set("vfs.devicePrefixes", []string{"xvd", "nvme"})
+
+	ctx := context.Background()
+	d := newDriver()
+	if !assert.NoError(t, d.Init(ctx, config)) {
+		t.FailNow()
+	}
+
+	ld, err := d.LocalDevices(ctx, &types.LocalDevicesOpts{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"/dev/xvda":    "/mnt/a",
+		"/dev/xvdb":    "",
+		"/dev/nvme0n1": "",
+		"/dev/nvme1n1": "/mnt/nvme1",
+		"/dev/nvme2n1": "/mnt/nvme2",
+	}, ld.DeviceMap)
+
+	dev, err := d.NextDevice(ctx, utils.NewStore())
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/xvdb", dev)
+}
+
+func TestLocalDevicesMissingOK(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := gofig.New()
+	config.Set("vfs.root", dir)
+
+	ctx := context.Background()
+	d := newDriver()
+	if !assert.NoError(t, d.Init(ctx, config)) {
+		t.FailNow()
+	}
+	os.Remove(path.Join(dir, "dev"))
+
+	_, err = d.LocalDevices(ctx, &types.LocalDevicesOpts{})
+	assert.EqualError(t, err, "device file missing")
+
+	config.Set("vfs.localDevicesMissingOK", true)
+	ld, err := d.LocalDevices(ctx, &types.LocalDevicesOpts{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, ld) {
+		assert.Empty(t, ld.DeviceMap)
+	}
+}
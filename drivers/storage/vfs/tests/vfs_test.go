@@ -20,6 +20,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/registry"
 	"github.com/emccode/libstorage/api/server"
 	apitests "github.com/emccode/libstorage/api/tests"
 	"github.com/emccode/libstorage/api/types"
@@ -29,6 +30,7 @@ import (
 
 	"github.com/emccode/libstorage/drivers/storage/vfs"
 	_ "github.com/emccode/libstorage/drivers/storage/vfs/client"
+	_ "github.com/emccode/libstorage/drivers/storage/vfs/executor"
 	_ "github.com/emccode/libstorage/drivers/storage/vfs/storage"
 )
 
@@ -693,6 +695,49 @@ func TestLocalDevices(t *testing.T) {
 		}).Test)
 }
 
+func TestLocalDevicesMissingFile(t *testing.T) {
+
+	d, err := ioutil.TempDir("", "")
+	if err != nil {
+		assert.NoError(t, err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+
+	ctx := context.Background()
+	config := gofig.New()
+	config.Set("vfs.root", d)
+
+	newExecutor := func() types.StorageExecutor {
+		x, err := registry.NewStorageExecutor(vfs.Name)
+		if err != nil {
+			assert.NoError(t, err)
+			t.FailNow()
+		}
+		if err := x.Init(ctx, config); err != nil {
+			assert.NoError(t, err)
+			t.FailNow()
+		}
+		return x
+	}
+
+	x := newExecutor()
+	os.Remove(vfs.DeviceFilePath(config))
+	ld, err := x.LocalDevices(ctx, &types.LocalDevicesOpts{})
+	assert.EqualError(t, err, "device file missing")
+	assert.Nil(t, ld)
+
+	config.Set("vfs.localDevicesMissingOK", true)
+	x = newExecutor()
+	os.Remove(vfs.DeviceFilePath(config))
+	ld, err = x.LocalDevices(ctx, &types.LocalDevicesOpts{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, ld) {
+		assert.Equal(t, vfs.Name, ld.Driver)
+		assert.Empty(t, ld.DeviceMap)
+	}
+}
+
 func removeTestDirs() {
 	testDirsLock.RLock()
 	defer testDirsLock.RUnlock()
@@ -10,6 +10,10 @@ import (
 const (
 	// Name is the name of the driver.
 	Name = "vfs"
+
+	// DefaultDevicePrefix is the prefix of the paths of the local devices
+	// when no prefixes are configured.
+	DefaultDevicePrefix = "/dev/xvd"
 )
 
 func init() {
@@ -20,6 +24,8 @@ func registerConfig() {
 	defaultRootDir := types.Lib.Join("vfs")
 	r := gofig.NewRegistration("VFS")
 	r.Key(gofig.String, "", defaultRootDir, "", "vfs.root")
+	r.Key(gofig.String, "", DefaultDevicePrefix, "", "vfs.devicePrefixes")
+	r.Key(gofig.Bool, "", false, "", "vfs.localDevicesMissingOK")
 	gofig.Register(r)
 }
 
@@ -33,6 +39,23 @@ func DeviceFilePath(config gofig.Config) string {
 	return path.Join(RootDir(config), "dev")
 }
 
+// DevicePrefixes returns the prefixes of the paths of the devices in the VFS
+// devices file that are local devices, such as /dev/xvd and /dev/nvme.
+func DevicePrefixes(config gofig.Config) []string {
+	prefixes := config.GetStringSlice("vfs.devicePrefixes")
+	if len(prefixes) == 0 {
+		return []string{DefaultDevicePrefix}
+	}
+	return prefixes
+}
+
+// LocalDevicesMissingOK returns a flag indicating whether a missing VFS
+// devices file is reported as an empty set of local devices instead of an
+// error.
+func LocalDevicesMissingOK(config gofig.Config) bool {
+	return config.GetBool("vfs.localDevicesMissingOK")
+}
+
 // VolumesDirPath returns the path to the VFS volumes directory.
 func VolumesDirPath(config gofig.Config) string {
 	return path.Join(RootDir(config), "vol")
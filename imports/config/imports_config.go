@@ -76,6 +76,42 @@ func init() {
 	rk(gofig.Bool, true, "", types.ConfigIgVolOpsPathCacheEnabled)
 	rk(gofig.Bool, true, "", types.ConfigIgVolOpsPathCacheAsync)
 	rk(gofig.String, "30m", "", types.ConfigClientCacheInstanceID)
+	rk(gofig.String, "", "", types.ConfigClientHTTPAcceptLanguage)
+	rk(gofig.Int, 0, "", types.ConfigClientHTTPCompressThreshold)
+	rk(gofig.Bool, false, "", types.ConfigClientHTTPCompression)
+	rk(gofig.Int, 3, "", types.ConfigClientHTTPMaxRetries)
+	rk(gofig.String, "100ms", "", types.ConfigClientHTTPRetryBackoff)
+	rk(gofig.String, "", "", types.ConfigClientHTTPRetryCodes)
+	rk(gofig.Bool, false, "", types.ConfigClientHTTPRetryNonIdempotent)
+	rk(gofig.String, "", "", types.ConfigClientHTTPDecodeTimeout)
+	rk(gofig.String, "", "", types.ConfigClientHTTPHedgeDelay)
+	rk(gofig.String, "", "", types.ConfigClientHTTPTimeout)
+	rk(gofig.String, "", "", types.ConfigClientHTTPMaxDataAge)
+	rk(gofig.String, "30s", "", types.ConfigClientHTTPKeepAlive)
+	rk(gofig.Bool, false, "", types.ConfigClientHTTPFollowLeaderRedirects)
+	rk(gofig.Int, 0, "", types.ConfigClientHTTPFailoverWarmup)
+	rk(gofig.Int, 0, "", types.ConfigClientHTTPMaxConcurrentDials)
+	rk(gofig.String, "blocking", "", types.ConfigClientHTTPBackpressureMode)
+	rk(gofig.Int, 2, "", types.ConfigClientHTTPMaxIdleConnsPerHost)
+	rk(gofig.String, "90s", "", types.ConfigClientHTTPIdleConnTimeout)
+	rk(gofig.Bool, false, "", types.ConfigClientHTTPTraceConnections)
+	rk(gofig.String, "", "", types.ConfigClientHTTPRecordFile)
+	rk(gofig.String, "", "", types.ConfigClientHTTPReplayFile)
+	rk(gofig.Bool, false, "", types.ConfigClientNotFoundAsNil)
+	rk(gofig.Bool, false, "", types.ConfigClientLazyDial)
+	rk(gofig.Bool, false, "", types.ConfigClientAutoDetachOnClose)
+	rk(gofig.Int, 0, "", types.ConfigClientMaxVolumeSize)
+	rk(gofig.Int, 0, "", types.ConfigClientMaxVolumesInResult)
+	rk(gofig.Int, 4, "", types.ConfigClientBulkConcurrency)
+	rk(gofig.Bool, false, "", types.ConfigClientReconcileAttach)
+	rk(gofig.String, "", "", types.ConfigClientAuditFile)
+	rk(gofig.Bool, false, "", types.ConfigClientExpvar)
+	rk(gofig.Int, 100, "", types.ConfigClientHealthWindow)
+	rk(gofig.Int, 10, "", types.ConfigClientHealthMinRequests)
+	rk(gofig.Int, 50, "", types.ConfigClientHealthDegradedPercent)
+	rk(gofig.Int, 25, "", types.ConfigClientHealthRecoveredPercent)
+	rk(gofig.String, "", "", types.ConfigClientVolumeNamePrefix)
+	rk(gofig.Bool, false, "", types.ConfigClientNameCaseFold)
 	rk(gofig.String, "30s", "", types.ConfigDeviceAttachTimeout)
 	rk(gofig.Int, 0, "", types.ConfigDeviceScanType)
 	rk(gofig.Bool, false, "", types.ConfigEmbedded)